@@ -5,43 +5,12 @@
 package cmd
 
 import (
-	"log"
-	"os"
+	"github.com/faelmori/keepgo/service"
 )
 
-var ConsoleLoggerObj = ConsoleLoggerImpl{}
+var ConsoleLoggerObj = ConsoleLoggerImpl{service.ConsoleLogger}
 
+// ConsoleLoggerImpl writes to stderr through service.ConsoleLogger.
 type ConsoleLoggerImpl struct {
-	info, warn, err *log.Logger
-}
-
-func init() {
-	ConsoleLoggerObj.info = log.New(os.Stderr, "I: ", log.Ltime)
-	ConsoleLoggerObj.warn = log.New(os.Stderr, "W: ", log.Ltime)
-	ConsoleLoggerObj.err = log.New(os.Stderr, "E: ", log.Ltime)
-}
-
-func (c ConsoleLoggerImpl) Error(v ...interface{}) error {
-	c.err.Print(v...)
-	return nil
-}
-func (c ConsoleLoggerImpl) Warning(v ...interface{}) error {
-	c.warn.Print(v...)
-	return nil
-}
-func (c ConsoleLoggerImpl) Info(v ...interface{}) error {
-	c.info.Print(v...)
-	return nil
-}
-func (c ConsoleLoggerImpl) Errorf(format string, a ...interface{}) error {
-	c.err.Printf(format, a...)
-	return nil
-}
-func (c ConsoleLoggerImpl) Warningf(format string, a ...interface{}) error {
-	c.warn.Printf(format, a...)
-	return nil
-}
-func (c ConsoleLoggerImpl) Infof(format string, a ...interface{}) error {
-	c.info.Printf(format, a...)
-	return nil
+	service.Logger
 }
@@ -3,3 +3,5 @@ module github.com/faelmori/keepgo
 go 1.23.6
 
 require golang.org/x/sys v0.30.0
+
+require github.com/godbus/dbus/v5 v5.2.2
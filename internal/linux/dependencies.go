@@ -0,0 +1,260 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"strings"
+)
+
+var systemdUnitSuffixes = []string{
+	".service", ".socket", ".target", ".timer", ".mount", ".automount",
+	".path", ".device", ".swap", ".slice", ".scope",
+}
+
+// Well-known systemd targets by their names in the other init systems.
+var (
+	openrcFacilities = map[string]string{
+		"network.target":        "net",
+		"network-online.target": "net",
+		"local-fs.target":       "localmount",
+		"remote-fs.target":      "netmount",
+		"syslog.target":         "logger",
+		"syslog.service":        "logger",
+		"time-sync.target":      "ntp-client",
+		"nss-lookup.target":     "dns",
+	}
+	lsbFacilities = map[string]string{
+		"network.target":        "$network",
+		"network-online.target": "$network",
+		"local-fs.target":       "$local_fs",
+		"remote-fs.target":      "$remote_fs",
+		"syslog.target":         "$syslog",
+		"syslog.service":        "$syslog",
+		"time-sync.target":      "$time",
+		"nss-lookup.target":     "$named",
+		"rpcbind.target":        "$portmap",
+	}
+	rcorderFacilities = map[string]string{
+		"network.target":        "NETWORKING",
+		"network-online.target": "NETWORKING",
+		"local-fs.target":       "FILESYSTEMS",
+		"remote-fs.target":      "mountcritremote",
+		"syslog.target":         "syslogd",
+		"syslog.service":        "syslogd",
+		"time-sync.target":      "ntpd",
+	}
+	upstartFacilities = map[string]string{
+		"syslog.target":  "rsyslog",
+		"syslog.service": "rsyslog",
+	}
+)
+
+// systemdDependencyDirectives renders d as [Unit] lines.
+func systemdDependencyDirectives(d *service.Dependencies) ([]string, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	after, wants := d.After, d.Wants
+	if d.NetworkOnline {
+		after = appendUnique(append([]string{}, after...), "network-online.target")
+		wants = appendUnique(append([]string{}, wants...), "network-online.target")
+	}
+
+	var lines []string
+	for _, l := range []struct {
+		key   string
+		names []string
+	}{
+		{"After", after}, {"Before", d.Before}, {"Requires", d.Requires}, {"Wants", wants},
+		{"BindsTo", d.BindsTo}, {"PartOf", d.PartOf}, {"Conflicts", d.Conflicts},
+	} {
+		if len(l.names) == 0 {
+			continue
+		}
+		units := make([]string, len(l.names))
+		for i, n := range l.names {
+			units[i] = systemdUnitName(n)
+		}
+		lines = append(lines, l.key+"="+strings.Join(units, " "))
+	}
+	return lines, nil
+}
+
+// systemdUnitName adds the .service suffix to bare service names.
+func systemdUnitName(name string) string {
+	for _, suffix := range systemdUnitSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return name
+		}
+	}
+	return name + ".service"
+}
+
+// dependencyName maps a dependency onto the naming of an init system other
+// than systemd. Services lose their .service suffix, other units are looked
+// up in facilities and yield "" when the init system has no equivalent.
+func dependencyName(name string, facilities map[string]string) string {
+	if f, ok := facilities[name]; ok {
+		return f
+	}
+	if unit := systemdUnitName(name); unit != name+".service" {
+		if strings.HasSuffix(unit, ".service") {
+			return strings.TrimSuffix(unit, ".service")
+		}
+		return ""
+	}
+	return name
+}
+
+func dependencyNames(facilities map[string]string, lists ...[]string) []string {
+	var names []string
+	for _, list := range lists {
+		for _, n := range list {
+			if n = dependencyName(n, facilities); n != "" {
+				names = appendUnique(names, n)
+			}
+		}
+	}
+	return names
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !containsString(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func containsString(list []string, v string) bool {
+	for _, existing := range list {
+		if existing == v {
+			return true
+		}
+	}
+	return false
+}
+
+// openrcDepend renders d as the lines of an OpenRC depend() function.
+// OpenRC cannot express PartOf and Conflicts.
+func openrcDepend(d *service.Dependencies) ([]string, error) {
+	if d == nil {
+		return nil, nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	need := dependencyNames(openrcFacilities, d.Requires, d.BindsTo)
+	if d.NetworkOnline {
+		need = appendUnique(need, "net")
+	}
+	var lines []string
+	for _, l := range []struct {
+		keyword string
+		names   []string
+	}{
+		{"need", need},
+		{"use", dependencyNames(openrcFacilities, d.Wants)},
+		{"after", dependencyNames(openrcFacilities, d.After)},
+		{"before", dependencyNames(openrcFacilities, d.Before)},
+	} {
+		if len(l.names) > 0 {
+			lines = append(lines, l.keyword+" "+strings.Join(l.names, " "))
+		}
+	}
+	return lines, nil
+}
+
+// upstartEvents returns the start on and stop on conditions for d. Jobs
+// start once everything they depend on has started and stop with the jobs
+// they are bound to. Upstart cannot express Before and Conflicts.
+func upstartEvents(d *service.Dependencies) (start, stop string, err error) {
+	startOn := []string{"runlevel [2345]"}
+	stopOn := []string{"runlevel [!2345]"}
+	if d == nil {
+		return startOn[0], stopOn[0], nil
+	}
+	if err := d.Validate(); err != nil {
+		return "", "", err
+	}
+
+	if d.NetworkOnline {
+		startOn = append(startOn, "net-device-up IFACE!=lo")
+	}
+	for _, n := range dependencyNames(upstartFacilities, d.Requires, d.BindsTo, d.Wants, d.After) {
+		startOn = append(startOn, "started "+n)
+	}
+	for _, n := range dependencyNames(upstartFacilities, d.BindsTo, d.PartOf) {
+		stopOn = append(stopOn, "stopping "+n)
+	}
+
+	start = strings.Join(startOn, " and ")
+	if len(startOn) > 1 {
+		start = "(" + start + ")"
+	}
+	return start, strings.Join(stopOn, " or "), nil
+}
+
+// lsbDependencies holds the dependency lines of an LSB init script header.
+type lsbDependencies struct {
+	RequiredStart string
+	RequiredStop  string
+	ShouldStart   string
+	ShouldStop    string
+	StartBefore   string // X-Start-Before, understood by insserv.
+}
+
+// lsbHeaders translates d into LSB facilities and script names. Every
+// service requires local and remote file systems and syslog, as is
+// conventional for daemons. LSB cannot express PartOf and Conflicts.
+func lsbHeaders(d *service.Dependencies) (lsbDependencies, error) {
+	if d == nil {
+		d = &service.Dependencies{}
+	}
+	if err := d.Validate(); err != nil {
+		return lsbDependencies{}, err
+	}
+
+	required := []string{"$remote_fs", "$syslog"}
+	if d.NetworkOnline {
+		required = append(required, "$network")
+	}
+	required = appendUnique(required, dependencyNames(lsbFacilities, d.Requires, d.BindsTo)...)
+	var should []string
+	for _, n := range dependencyNames(lsbFacilities, d.Wants, d.After) {
+		if !containsString(required, n) {
+			should = append(should, n)
+		}
+	}
+
+	return lsbDependencies{
+		RequiredStart: strings.Join(required, " "),
+		RequiredStop:  strings.Join(required, " "),
+		ShouldStart:   strings.Join(should, " "),
+		ShouldStop:    strings.Join(should, " "),
+		StartBefore:   strings.Join(dependencyNames(lsbFacilities, d.Before), " "),
+	}, nil
+}
+
+// rcorderHeaders returns the REQUIRE and BEFORE names of a BSD rc.d script
+// for d. Every service starts after LOGIN, as is conventional for local
+// daemons. rcorder only orders, requirements are not started.
+func rcorderHeaders(d *service.Dependencies) (require, before []string, err error) {
+	require = []string{"LOGIN"}
+	if d == nil {
+		return require, nil, nil
+	}
+	if err := d.Validate(); err != nil {
+		return nil, nil, err
+	}
+	if d.NetworkOnline {
+		require = append(require, "NETWORKING")
+	}
+	require = appendUnique(require, dependencyNames(rcorderFacilities, d.Requires, d.BindsTo, d.Wants, d.After)...)
+	return require, dependencyNames(rcorderFacilities, d.Before), nil
+}
@@ -0,0 +1,117 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testDependencies = &service.Dependencies{
+	After:         []string{"postgresql", "local-fs.target"},
+	Before:        []string{"nginx.service"},
+	Requires:      []string{"postgresql"},
+	Wants:         []string{"redis", "syslog.target"},
+	BindsTo:       []string{"vault"},
+	PartOf:        []string{"app.target"},
+	Conflicts:     []string{"legacy"},
+	NetworkOnline: true,
+}
+
+func TestSystemdDependencyDirectives(t *testing.T) {
+	lines, err := systemdDependencyDirectives(testDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"After=postgresql.service local-fs.target network-online.target",
+		"Before=nginx.service",
+		"Requires=postgresql.service",
+		"Wants=redis.service syslog.target network-online.target",
+		"BindsTo=vault.service",
+		"PartOf=app.target",
+		"Conflicts=legacy.service",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+
+	s, _ := newTestSystemdService(t, &service.Config{Name: "api", Dependencies: testDependencies})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "\nBindsTo=vault.service\n") {
+		t.Errorf("Expected the dependencies in the unit:\n%s", unit)
+	}
+}
+
+func TestOpenRCDepend(t *testing.T) {
+	lines, err := openrcDepend(testDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"need postgresql vault net", "use redis logger", "after postgresql localmount", "before nginx"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
+
+func TestRcorderHeaders(t *testing.T) {
+	require, before, err := rcorderHeaders(testDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"LOGIN", "NETWORKING", "postgresql", "vault", "redis", "syslogd", "FILESYSTEMS"}
+	if !reflect.DeepEqual(require, want) || !reflect.DeepEqual(before, []string{"nginx"}) {
+		t.Errorf("Expected %q and [nginx], got %q and %q", want, require, before)
+	}
+}
+
+func TestUpstartEvents(t *testing.T) {
+	start, stop, err := upstartEvents(testDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(runlevel [2345] and net-device-up IFACE!=lo and started postgresql and started vault and started redis and started rsyslog)"; start != want {
+		t.Errorf("Expected start on %q, got %q", want, start)
+	}
+	if want := "runlevel [!2345] or stopping vault"; stop != want {
+		t.Errorf("Expected stop on %q, got %q", want, stop)
+	}
+
+	start, stop, _ = upstartEvents(nil)
+	if start != "runlevel [2345]" || stop != "runlevel [!2345]" {
+		t.Errorf("Expected the runlevel defaults, got %q and %q", start, stop)
+	}
+}
+
+func TestLSBHeaders(t *testing.T) {
+	h, err := lsbHeaders(testDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := lsbDependencies{
+		RequiredStart: "$remote_fs $syslog $network postgresql vault",
+		RequiredStop:  "$remote_fs $syslog $network postgresql vault",
+		ShouldStart:   "redis $local_fs",
+		ShouldStop:    "redis $local_fs",
+		StartBefore:   "nginx",
+	}
+	if h != want {
+		t.Errorf("Expected %+v, got %+v", want, h)
+	}
+}
+
+func TestDependenciesValidate(t *testing.T) {
+	for _, d := range []*service.Dependencies{
+		{After: []string{""}},
+		{Requires: []string{"two words"}},
+		{Wants: []string{"a;reboot"}},
+		{Conflicts: []string{"../etc"}},
+	} {
+		if _, err := systemdDependencyDirectives(d); err == nil {
+			t.Errorf("Expected %+v to be rejected", d)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package linux
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Directories distributions keep per-service environment files in.
+var (
+	sysconfigDir  = "/etc/sysconfig"
+	etcDefaultDir = "/etc/default"
+)
+
+// envFileDir returns /etc/sysconfig on Red Hat and SUSE style systems and
+// /etc/default everywhere else.
+func envFileDir() string {
+	if info, err := os.Stat(sysconfigDir); err == nil && info.IsDir() {
+		return sysconfigDir
+	}
+	return etcDefaultDir
+}
+
+// renderEnvFile renders vars and secrets as KEY="value" lines, which
+// systemd's EnvironmentFile= and a POSIX shell sourcing the file read the
+// same way. Secrets win over vars of the same name.
+func renderEnvFile(vars, secrets map[string]string) ([]byte, error) {
+	merged := make(map[string]string, len(vars)+len(secrets))
+	for k, v := range vars {
+		merged[k] = v
+	}
+	for k, v := range secrets {
+		merged[k] = v
+	}
+	names := make([]string, 0, len(merged))
+	for k := range merged {
+		if !envNamePattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid environment variable name %q", k)
+		}
+		if v := merged[k]; hasControl(strings.ReplaceAll(v, "\n", "")) || !utf8.ValidString(v) {
+			return nil, fmt.Errorf("environment variable %s: value holds a control character or invalid UTF-8", k)
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	b.WriteString("# Managed by keepgo, changes are overwritten.\n")
+	for _, k := range names {
+		b.WriteString(k + "=" + envQuote(merged[k]) + "\n")
+	}
+	return b.Bytes(), nil
+}
+
+// renderMaskedEnvFile is renderEnvFile with the secret values blanked out,
+// for previews.
+func renderMaskedEnvFile(vars, secrets map[string]string) ([]byte, error) {
+	masked := make(map[string]string, len(secrets))
+	for k := range secrets {
+		masked[k] = "********"
+	}
+	return renderEnvFile(vars, masked)
+}
+
+// envQuote double quotes v, escaping the characters a shell treats
+// specially there: backslash, double quote, backquote and dollar. systemd
+// reads those escapes the same way and, like the shell, keeps newlines
+// inside quotes. The two disagree on quotes escaped within single quotes.
+func envQuote(v string) string {
+	return `"` + envQuoteReplacer.Replace(v) + `"`
+}
+
+var envQuoteReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", `$`, `\$`)
+
+// writeEnvFile replaces path with content, readable by its owner only.
+// The file is written aside and renamed so it is never seen half written
+// or with looser permissions.
+func writeEnvFile(path string, content []byte, uid, gid int) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	if os.Geteuid() == 0 {
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
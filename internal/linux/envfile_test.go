@@ -0,0 +1,204 @@
+package linux
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenderEnvFile(t *testing.T) {
+	vars := map[string]string{
+		"PLAIN":     "value",
+		"SPACES":    "  hello world  ",
+		"QUOTES":    `it's "quoted"`,
+		"DOLLAR":    "$HOME and ${HOME}",
+		"BACKQUOTE": "`date`",
+		"BACKSLASH": `C:\temp\new \\ end\`,
+		"NEWLINE":   "one\ntwo\n",
+		"COMMENT":   "# not a comment ; either",
+		"UNICODE":   "grüße",
+		"EMPTY":     "",
+		"TOKEN":     "public",
+	}
+	content, err := renderEnvFile(vars, map[string]string{"TOKEN": "s3cr3t"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), `QUOTES="it's \"quoted\""`+"\n") {
+		t.Errorf("Unexpected quoting:\n%s", content)
+	}
+	vars["TOKEN"] = "s3cr3t"
+
+	// systemd has to read back exactly what was written.
+	got := parseSystemdEnvFile(string(content))
+	for k, want := range vars {
+		if got[k] != want {
+			t.Errorf("%s: systemd reads %q, want %q", k, got[k], want)
+		}
+	}
+	if len(got) != len(vars) {
+		t.Errorf("Expected %d variables, systemd reads %v", len(vars), got)
+	}
+
+	// And so does the shell.
+	path := filepath.Join(t.TempDir(), "env")
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range vars {
+		out, err := exec.Command("sh", "-c", `. "$0" && printf %s "$`+k+`"`, path).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != want {
+			t.Errorf("%s: sourced %q, want %q", k, out, want)
+		}
+	}
+
+	if _, err := renderEnvFile(map[string]string{"NOT VALID": "x"}, nil); err == nil {
+		t.Error("Expected an invalid variable name to be rejected")
+	}
+	for _, v := range []string{"a\tb", "a\rb", "a\x00b", "\x1b[31m", "a\x7fb", "\xff"} {
+		if _, err := renderEnvFile(nil, map[string]string{"V": v}); err == nil {
+			t.Errorf("Expected %q to be rejected", v)
+		}
+	}
+}
+
+// parseSystemdEnvFile reads an environment file the way systemd's
+// EnvironmentFile= does, after parse_env_file_internal in env-file.c.
+func parseSystemdEnvFile(content string) map[string]string {
+	const (
+		preKey = iota
+		key
+		preValue
+		value
+		valueEscape
+		singleQuote
+		doubleQuote
+		doubleQuoteEscape
+		comment
+	)
+	vars := map[string]string{}
+	state := preKey
+	var k, v strings.Builder
+	push := func() {
+		if state == value {
+			vars[strings.TrimSpace(k.String())] = strings.TrimRight(v.String(), " \t")
+		} else {
+			vars[strings.TrimSpace(k.String())] = v.String()
+		}
+		k.Reset()
+		v.Reset()
+	}
+	for _, c := range content + "\n" {
+		newline := c == '\n' || c == '\r'
+		switch state {
+		case preKey:
+			if c == '#' || c == ';' {
+				state = comment
+			} else if !strings.ContainsRune(" \t\n\r", c) {
+				state = key
+				k.WriteRune(c)
+			}
+		case key:
+			if newline {
+				state = preKey
+				k.Reset()
+			} else if c == '=' {
+				state = preValue
+			} else {
+				k.WriteRune(c)
+			}
+		case preValue:
+			switch {
+			case newline:
+				push()
+				state = preKey
+			case c == '\'':
+				state = singleQuote
+			case c == '"':
+				state = doubleQuote
+			case c == '\\':
+				state = valueEscape
+			case c != ' ' && c != '\t':
+				state = value
+				v.WriteRune(c)
+			}
+		case value:
+			if newline {
+				push()
+				state = preKey
+			} else if c == '\\' {
+				state = valueEscape
+			} else {
+				v.WriteRune(c)
+			}
+		case valueEscape:
+			state = value
+			if !newline {
+				v.WriteRune(c)
+			}
+		case singleQuote:
+			if c == '\'' {
+				state = preValue
+			} else {
+				v.WriteRune(c)
+			}
+		case doubleQuote:
+			if c == '"' {
+				state = preValue
+			} else if c == '\\' {
+				state = doubleQuoteEscape
+			} else {
+				v.WriteRune(c)
+			}
+		case doubleQuoteEscape:
+			state = doubleQuote
+			if strings.ContainsRune("\"\\`$", c) {
+				v.WriteRune(c)
+			} else if c != '\n' {
+				v.WriteRune('\\')
+				v.WriteRune(c)
+			}
+		case comment:
+			if newline {
+				state = preKey
+			}
+		}
+	}
+	return vars
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "default", "api")
+	if err := writeEnvFile(path, []byte("A=\"1\"\n"), os.Getuid(), os.Getgid()); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("Expected no temporary files left, got %v", entries)
+	}
+}
+
+func TestEnvFileDir(t *testing.T) {
+	defer func(s, d string) { sysconfigDir, etcDefaultDir = s, d }(sysconfigDir, etcDefaultDir)
+	root := t.TempDir()
+	sysconfigDir, etcDefaultDir = filepath.Join(root, "sysconfig"), filepath.Join(root, "default")
+	if got := envFileDir(); got != etcDefaultDir {
+		t.Errorf("Expected %s without /etc/sysconfig, got %s", etcDefaultDir, got)
+	}
+	os.Mkdir(sysconfigDir, 0755)
+	if got := envFileDir(); got != sysconfigDir {
+		t.Errorf("Expected %s, got %s", sysconfigDir, got)
+	}
+}
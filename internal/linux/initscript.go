@@ -0,0 +1,284 @@
+package linux
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+)
+
+// initService holds what the init script backends, upstart, OpenRC, rc.d
+// and SysV, have in common. Each of them embeds it and describes its
+// environment file in env.
+type initService struct {
+	Name     string
+	Config   *service.Config
+	i        service.Controller
+	platform string
+	runner   runners.Runner
+	env      initEnv
+}
+
+// initEnv describes the environment file of an init script backend.
+type initEnv struct {
+	path        func() (string, error)
+	secretsOnly bool // EnvVars are set by the script itself.
+	exports     bool // The init system only sources the file, so it exports the variables.
+	userOwned   bool // The file is read after the privilege drop, so it belongs to the service user.
+}
+
+func newInitService(i service.Controller, platform string, c *service.Config, r *runners.Runner, env initEnv) initService {
+	return initService{
+		Name:     c.Name,
+		Config:   c,
+		i:        i,
+		platform: platform,
+		runner:   runners.Resolve(r),
+		env:      env,
+	}
+}
+
+// runService runs svc, the backend embedding s, in the foreground until
+// it is signalled.
+func (s *initService) runService(svc service.Service) error {
+	err := s.i.Start(svc)
+	if err != nil {
+		return err
+	}
+	s.Config.Option.FuncSingle(service.OptionRunWait, func() {
+		var sigChan = make(chan os.Signal, 3)
+		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
+		<-sigChan
+	})()
+	return s.i.Stop(svc)
+}
+
+// install writes the environment file and the script at path, then calls
+// enable. A failure removes what was written.
+func (s *initService) install(path string, script []byte, mode os.FileMode, enable func() error) error {
+	_, err := os.Stat(path)
+	if err == nil {
+		return fmt.Errorf("init already exists: %s", path)
+	}
+
+	if s.hasEnvironment() {
+		err = s.WriteEnvironment()
+		if err != nil {
+			return err
+		}
+	}
+	err = os.WriteFile(path, script, mode)
+	if err == nil {
+		err = enable()
+		if err != nil {
+			_ = os.Remove(path)
+		}
+	}
+	if err != nil {
+		if s.hasEnvironment() {
+			_ = s.RemoveEnvironment()
+		}
+		return fmt.Errorf("install rolled back: %w", err)
+	}
+	return nil
+}
+
+// removeFiles removes the script at path and the environment file.
+func (s *initService) removeFiles(path string) error {
+	err := os.Remove(path)
+	if err != nil {
+		return err
+	}
+	if s.hasEnvironment() {
+		return s.RemoveEnvironment()
+	}
+	return nil
+}
+
+// render previews install: the script at path, the environment file with
+// the secrets masked, and commands.
+func (s *initService) render(path string, script []byte, mode os.FileMode, commands ...[]string) (*service.Rendering, error) {
+	r := &service.Rendering{}
+	r.Files = append(r.Files, service.RenderedFile{Path: path, Mode: mode, Content: script})
+	if s.hasEnvironment() {
+		envPath, err := s.EnvironmentFile()
+		if err != nil {
+			return nil, err
+		}
+		content, err := s.environment(true)
+		if err != nil {
+			return nil, err
+		}
+		r.Files = append(r.Files, service.RenderedFile{Path: envPath, Mode: 0600, Content: content})
+	}
+	r.Commands = append(r.Commands, commands...)
+	return r, nil
+}
+
+// GetLogger writes to the terminal when run by hand, and to syslog under
+// the init system.
+func (s *initService) GetLogger(errs chan<- error) (service.Logger, error) {
+	if service.Interactive() {
+		return service.ConsoleLogger, nil
+	}
+	return s.SystemLogger(errs)
+}
+func (s *initService) SystemLogger(errs chan<- error) (service.Logger, error) {
+	return NewSysLogger(s.Name, errs)
+}
+func (s *initService) String() string { return s.Name }
+
+// EnvironmentFile implements service.EnvironmentManager.
+func (s *initService) EnvironmentFile() (string, error) {
+	return s.env.path()
+}
+
+// hasEnvironment reports whether the Config asks for an environment file.
+func (s *initService) hasEnvironment() bool {
+	return len(s.Config.SecretEnvVars) > 0 || (!s.env.secretsOnly && len(s.Config.EnvVars) > 0)
+}
+
+// environment renders the environment file, with the secrets blanked out
+// when masked is set.
+func (s *initService) environment(masked bool) ([]byte, error) {
+	vars := s.Config.EnvVars
+	if s.env.secretsOnly {
+		vars = nil
+	}
+	render := renderEnvFile
+	if masked {
+		render = renderMaskedEnvFile
+	}
+	content, err := render(vars, s.Config.SecretEnvVars)
+	if err != nil || !s.env.exports {
+		return content, err
+	}
+	var names []string
+	for k := range vars {
+		names = append(names, k)
+	}
+	for k := range s.Config.SecretEnvVars {
+		names = appendUnique(names, k)
+	}
+	sort.Strings(names)
+	return append(content, "export "+strings.Join(names, " ")+"\n"...), nil
+}
+
+// WriteEnvironment implements service.EnvironmentManager.
+func (s *initService) WriteEnvironment() error {
+	path, err := s.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	content, err := s.environment(false)
+	if err != nil {
+		return err
+	}
+	uid, gid, err := s.environmentOwner()
+	if err != nil {
+		return err
+	}
+	return writeEnvFile(path, content, uid, gid)
+}
+
+// environmentOwner returns the owner of the environment file: root, or with
+// env.userOwned the account the service runs as.
+func (s *initService) environmentOwner() (uid, gid int, err error) {
+	if !s.env.userOwned || s.Config.UserName == "" {
+		return 0, 0, nil
+	}
+	u, err := user.Lookup(s.Config.UserName)
+	if err != nil {
+		return 0, 0, err
+	}
+	groupID := u.Gid
+	if group := s.Config.Option.String(service.OptionGroup, ""); group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return 0, 0, err
+		}
+		groupID = g.Gid
+	}
+	uid, err = strconv.Atoi(u.Uid)
+	if err != nil {
+		return 0, 0, err
+	}
+	gid, err = strconv.Atoi(groupID)
+	return uid, gid, err
+}
+
+// RemoveEnvironment implements service.EnvironmentManager.
+func (s *initService) RemoveEnvironment() error {
+	path, err := s.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// environmentPath returns the environment file for the script to load,
+// empty when there is none.
+func (s *initService) environmentPath() (string, error) {
+	if !s.hasEnvironment() {
+		return "", nil
+	}
+	return s.EnvironmentFile()
+}
+
+// user returns the account to run the daemon as, user:group when
+// OptionGroup is set.
+func (s *initService) user() string {
+	user := s.Config.UserName
+	if group := s.Config.Option.String(service.OptionGroup, ""); user != "" && group != "" {
+		user += ":" + group
+	}
+	return user
+}
+
+func (s *initService) ExecPath() (string, error) {
+	if s.Config.Executable != "" {
+		return s.Config.ExecPath()
+	}
+	return exec.LookPath(s.Name)
+}
+func (s *initService) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	return s.runner.RunWithOutput(command, arguments...)
+}
+func (s *initService) runCommand(command string, args ...string) error {
+	_, _, err := s.RunWithOutput(command, args...)
+	return err
+}
+
+// shellQuote single quotes v for a POSIX shell. Nothing is special inside
+// single quotes; a quote itself closes the quoting, is escaped with a
+// backslash and reopens it.
+func shellQuote(v string) string {
+	return "'" + strings.ReplaceAll(v, "'", `'\''`) + "'"
+}
+
+// renderTemplate executes the script template text with data.
+func renderTemplate(text string, funcs template.FuncMap, data interface{}) ([]byte, error) {
+	tmpl, err := template.New("").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
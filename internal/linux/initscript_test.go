@@ -0,0 +1,240 @@
+package linux
+
+import (
+	"errors"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setTestVar sets the package variable v for the duration of the test.
+func setTestVar(t *testing.T, v *string, value string) {
+	old := *v
+	*v = value
+	t.Cleanup(func() { *v = old })
+}
+
+// newTestInitService builds an init script backend with newService and a
+// fake runner.
+func newTestInitService(t *testing.T, c *service.Config, platform string, newService func(service.Controller, string, *service.Config, *runners.Runner) (service.Service, error)) (service.Service, *fakeRunner) {
+	if c.Executable == "" {
+		c.Executable = "/usr/bin/" + c.Name
+	}
+	r := &fakeRunner{outputs: map[string]string{}}
+	var runner runners.Runner = r
+	svc, err := newService(&testController{}, platform, c, &runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc, r
+}
+
+// setTestLookPath makes *lookPath find only tools, in /usr/sbin, for the
+// duration of the test.
+func setTestLookPath(t *testing.T, lookPath *func(string) (string, error), tools ...string) {
+	old := *lookPath
+	*lookPath = func(file string) (string, error) {
+		if containsString(tools, file) {
+			return "/usr/sbin/" + file, nil
+		}
+		return "", exec.ErrNotFound
+	}
+	t.Cleanup(func() { *lookPath = old })
+}
+
+// statusTest is a reply of the status command, an exit code and its
+// output, and the status it stands for.
+type statusTest struct {
+	code int
+	out  string
+	want service.Status
+	fail bool
+}
+
+// initBackend describes an init script backend for TestInitServiceBackends.
+type initBackend struct {
+	name       string
+	newService func(t *testing.T, c *service.Config) (service.Service, *fakeRunner)
+	mode       os.FileMode              // Of the script or job file.
+	enable     string                   // Prefix of the command line enabling the service.
+	calls      string                   // Commands run by Install and Uninstall.
+	status     func(path string) string // The status command line for the script at path.
+	missing    error                    // Reply of the status command for a service not installed, nil when the missing file tells.
+	statuses   []statusTest
+}
+
+var initBackends = []initBackend{
+	{
+		name: "upstart",
+		newService: func(t *testing.T, c *service.Config) (service.Service, *fakeRunner) {
+			return newTestUpstartService(t, c)
+		},
+		mode:    0644,
+		enable:  "initctl reload-configuration",
+		calls:   "initctl reload-configuration|initctl reload-configuration",
+		status:  func(string) string { return "initctl status app" },
+		missing: &runners.ExitError{Command: "initctl", ExitCode: 1, Stderr: "initctl: Unknown job: app\n"},
+		statuses: []statusTest{
+			{0, "app start/running, process 1234\n", service.StatusRunning, false},
+			{0, "app start/pre-start, process 99\n", service.StatusRunning, false},
+			{0, "app stop/waiting\n", service.StatusStopped, false},
+			{0, "garbage", service.StatusUnknown, true},
+		},
+	},
+	{
+		name: "openrc",
+		newService: func(t *testing.T, c *service.Config) (service.Service, *fakeRunner) {
+			return newTestOpenRCService(t, c)
+		},
+		mode:   0755,
+		enable: "rc-update add",
+		calls:  "rc-update add app default|rc-update del app default",
+		status: func(string) string { return "rc-service app status" },
+		statuses: []statusTest{
+			{0, "", service.StatusRunning, false},
+			{3, "", service.StatusStopped, false},
+			{4, "", service.StatusStopped, false},
+			{8, "", service.StatusRunning, false},
+			{16, "", service.StatusStopped, false},
+			{32, "", service.StatusUnknown, true},
+		},
+	},
+	{
+		name: "rcs",
+		newService: func(t *testing.T, c *service.Config) (service.Service, *fakeRunner) {
+			return newTestRCSService(t, c, "sysrc")
+		},
+		mode:   0755,
+		enable: "sysrc app_enable=YES",
+		calls:  "sysrc app_enable=YES|sysrc -i -x app_enable",
+		status: func(string) string { return "service app onestatus" },
+		statuses: []statusTest{
+			{0, "", service.StatusRunning, false},
+			{1, "", service.StatusStopped, false},
+			{2, "", service.StatusUnknown, true},
+		},
+	},
+	{
+		name: "sysv",
+		newService: func(t *testing.T, c *service.Config) (service.Service, *fakeRunner) {
+			return newTestSysVService(t, c, "update-rc.d")
+		},
+		mode:   0755,
+		enable: "update-rc.d",
+		calls:  "update-rc.d app defaults|update-rc.d -f app remove",
+		status: func(path string) string { return path + " status" },
+		statuses: []statusTest{
+			{0, "", service.StatusRunning, false},
+			{1, "", service.StatusStopped, false},
+			{2, "", service.StatusStopped, false},
+			{3, "", service.StatusStopped, false},
+			{4, "", service.StatusUnknown, true},
+		},
+	},
+}
+
+// TestInitServiceBackends installs, queries and removes a service with
+// environment variables and secrets on every init script backend, and has
+// the install fail once enabling it.
+func TestInitServiceBackends(t *testing.T) {
+	for _, b := range initBackends {
+		t.Run(b.name, func(t *testing.T) {
+			s, r := b.newService(t, &service.Config{
+				Name:          "app",
+				EnvVars:       map[string]string{"PORT": "8080"},
+				SecretEnvVars: map[string]string{"TOKEN": "s3cret"},
+			})
+			path := s.(interface{ ConfigPath() string }).ConfigPath()
+			env, err := s.(service.EnvironmentManager).EnvironmentFile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			status := b.status(path)
+
+			if b.missing != nil {
+				r.failures = map[string]error{status: b.missing}
+			}
+			if _, err := s.Status(); err != service.ErrNotInstalled {
+				t.Errorf("Expected ErrNotInstalled before install, got %v", err)
+			}
+
+			r.failures = map[string]error{b.enable: errors.New("boom")}
+			if err := s.Install(); err == nil || !strings.Contains(err.Error(), "rolled back") {
+				t.Errorf("Expected a rolled back install, got %v", err)
+			}
+			for _, p := range []string{path, env} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be rolled back, got %v", p, err)
+				}
+			}
+
+			r.failures, r.calls = nil, nil
+			if err := s.Install(); err != nil {
+				t.Fatal(err)
+			}
+			if info, err := os.Stat(path); err != nil || info.Mode().Perm() != b.mode {
+				t.Errorf("Expected %s with mode %v, got %v, %v", path, b.mode, info, err)
+			}
+			if info, err := os.Stat(env); err != nil || info.Mode().Perm() != 0600 {
+				t.Errorf("Expected a 0600 environment file, got %v, %v", info, err)
+			}
+			if err := s.Install(); err == nil {
+				t.Error("Expected installing twice to fail")
+			}
+			calls := r.calls
+
+			for _, tt := range b.statuses {
+				r.failures = nil
+				r.outputs[status] = tt.out
+				if tt.code != 0 {
+					r.failures = map[string]error{status: &runners.ExitError{Command: status, ExitCode: tt.code}}
+				}
+				got, err := s.Status()
+				if got != tt.want || (err != nil) != tt.fail {
+					t.Errorf("Status() for exit code %d and %q = %v, %v, want %v", tt.code, tt.out, got, err, tt.want)
+				}
+			}
+
+			r.failures, r.calls = nil, calls
+			if err := s.Uninstall(); err != nil {
+				t.Fatal(err)
+			}
+			if got := strings.Join(r.calls, "|"); got != b.calls {
+				t.Errorf("Expected calls %q, got %q", b.calls, got)
+			}
+			for _, p := range []string{path, env} {
+				if _, err := os.Stat(p); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be removed, got %v", p, err)
+				}
+			}
+		})
+	}
+}
+
+func TestInitServiceEnvironmentFileError(t *testing.T) {
+	dir := t.TempDir()
+	boom := errors.New("no environment file")
+	s := &initService{
+		Name:   "app",
+		Config: &service.Config{Name: "app", SecretEnvVars: map[string]string{"TOKEN": "s3cret"}},
+		runner: &fakeRunner{},
+		env:    initEnv{path: func() (string, error) { return "", boom }},
+	}
+	script := filepath.Join(dir, "app")
+	if err := s.install(script, nil, 0755, func() error { return nil }); !errors.Is(err, boom) {
+		t.Errorf("Expected the install to fail with %v, got %v", boom, err)
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("Expected no script to be written, got %v", err)
+	}
+	if _, err := s.render(script, nil, 0755); !errors.Is(err, boom) {
+		t.Errorf("Expected the rendering to fail with %v, got %v", boom, err)
+	}
+	if _, err := s.environmentPath(); !errors.Is(err, boom) {
+		t.Errorf("Expected the script data to fail with %v, got %v", boom, err)
+	}
+}
@@ -0,0 +1,162 @@
+package linux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// journalSocket is where journald accepts entries in its native protocol.
+var journalSocket = "/run/systemd/journal/socket"
+
+// NewJournalLogger returns a Logger writing to journald through its native
+// protocol, see systemd.journal-fields(7).
+func NewJournalLogger(name string, errs chan<- error) (service.Logger, error) {
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journalLogger{
+		conn:       conn,
+		addr:       &net.UnixAddr{Name: journalSocket, Net: "unixgram"},
+		identifier: name,
+		errs:       errs,
+	}, nil
+}
+
+// journalAvailable reports whether the process runs as a systemd service
+// with journald listening.
+func journalAvailable() bool {
+	if os.Getenv("INVOCATION_ID") == "" && os.Getenv("JOURNAL_STREAM") == "" {
+		return false
+	}
+	_, err := os.Stat(journalSocket)
+	return err == nil
+}
+
+type journalLogger struct {
+	conn       *net.UnixConn
+	addr       *net.UnixAddr
+	identifier string
+	fields     map[string]string
+	errs       chan<- error
+}
+
+// WithFields implements service.FieldLogger. Field names are upper-cased and
+// stripped of characters journald rejects.
+func (l *journalLogger) WithFields(fields map[string]string) service.Logger {
+	merged := make(map[string]string, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		if name := journalFieldName(k); name != "" {
+			merged[name] = v
+		}
+	}
+	c := *l
+	c.fields = merged
+	return &c
+}
+
+func (l *journalLogger) Error(v ...interface{}) error {
+	return l.log(service.PriorityErr, fmt.Sprint(v...))
+}
+func (l *journalLogger) Warning(v ...interface{}) error {
+	return l.log(service.PriorityWarning, fmt.Sprint(v...))
+}
+func (l *journalLogger) Info(v ...interface{}) error {
+	return l.log(service.PriorityInfo, fmt.Sprint(v...))
+}
+func (l *journalLogger) Errorf(format string, a ...interface{}) error {
+	return l.log(service.PriorityErr, fmt.Sprintf(format, a...))
+}
+func (l *journalLogger) Warningf(format string, a ...interface{}) error {
+	return l.log(service.PriorityWarning, fmt.Sprintf(format, a...))
+}
+func (l *journalLogger) Infof(format string, a ...interface{}) error {
+	return l.log(service.PriorityInfo, fmt.Sprintf(format, a...))
+}
+
+// log sends one entry. It must be called straight from the exported
+// methods so the caller recorded in CODE_FILE is the one logging.
+func (l *journalLogger) log(priority int, message string) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", message)
+	writeJournalField(&b, "PRIORITY", strconv.Itoa(priority))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", l.identifier)
+	if pc, file, line, ok := runtime.Caller(2); ok {
+		writeJournalField(&b, "CODE_FILE", file)
+		writeJournalField(&b, "CODE_LINE", strconv.Itoa(line))
+		if f := runtime.FuncForPC(pc); f != nil {
+			writeJournalField(&b, "CODE_FUNC", f.Name())
+		}
+	}
+	for k, v := range l.fields {
+		writeJournalField(&b, k, v)
+	}
+
+	err := l.send(b.Bytes())
+	if err != nil && l.errs != nil {
+		l.errs <- err
+	}
+	return err
+}
+
+// send writes an entry as one datagram, passing it in a sealed memfd when
+// it exceeds the socket's datagram size limit.
+func (l *journalLogger) send(entry []byte) error {
+	_, _, err := l.conn.WriteMsgUnix(entry, nil, l.addr)
+	if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		return err
+	}
+
+	f, err := sealedMemfd("journal-entry", entry)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, _, err = l.conn.WriteMsgUnix(nil, syscall.UnixRights(int(f.Fd())), l.addr)
+	return err
+}
+
+// writeJournalField appends a field to an entry. Values spanning lines use
+// the binary form: the name, a newline and the little-endian 64 bit length
+// followed by the value.
+func writeJournalField(b *bytes.Buffer, name, value string) {
+	if !strings.ContainsRune(value, '\n') {
+		b.WriteString(name + "=" + value + "\n")
+		return
+	}
+	b.WriteString(name + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName turns name into a valid journal field name: upper case
+// letters, digits and underscores, not starting with an underscore or a
+// digit, at most 64 characters. It returns "" when nothing remains.
+func journalFieldName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(name) {
+		switch {
+		case r >= 'A' && r <= 'Z', r == '_' && b.Len() > 0, r >= '0' && r <= '9' && b.Len() > 0:
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteByte('_')
+		}
+	}
+	n := b.String()
+	if len(n) > 64 {
+		n = n[:64]
+	}
+	return n
+}
@@ -0,0 +1,26 @@
+package linux
+
+import (
+	"golang.org/x/sys/unix"
+	"os"
+)
+
+// sealedMemfd returns a memfd holding content, sealed against any change
+// as journald requires of entries passed by descriptor.
+func sealedMemfd(name string, content []byte) (*os.File, error) {
+	fd, err := unix.MemfdCreate(name, unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), name)
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return nil, err
+	}
+	_, err = unix.FcntlInt(f.Fd(), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
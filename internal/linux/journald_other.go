@@ -0,0 +1,14 @@
+//go:build !linux
+
+package linux
+
+import (
+	"errors"
+	"os"
+)
+
+// sealedMemfd is only available on Linux, where journald runs; entries too
+// large for a datagram fail to send elsewhere.
+func sealedMemfd(name string, content []byte) (*os.File, error) {
+	return nil, errors.New("journal: sealed memfd not supported on this platform")
+}
@@ -0,0 +1,140 @@
+package linux
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/faelmori/keepgo/service"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// listenJournal points the journal logger at a local socket.
+func listenJournal(t *testing.T) *net.UnixConn {
+	path := filepath.Join(t.TempDir(), "socket")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	old := journalSocket
+	journalSocket = path
+	t.Cleanup(func() { journalSocket = old })
+	return conn
+}
+
+// readJournalEntry receives one entry, reading it from a passed memfd when
+// the datagram is empty, and parses both field encodings.
+func readJournalEntry(t *testing.T, conn *net.UnixConn) map[string]string {
+	buf := make([]byte, 1<<16)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := buf[:n]
+	if n == 0 {
+		msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+		if err != nil || len(msgs) != 1 {
+			t.Fatalf("Expected a passed file descriptor, got %v, %v", msgs, err)
+		}
+		fds, err := unix.ParseUnixRights(&msgs[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := os.NewFile(uintptr(fds[0]), "memfd")
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = make([]byte, info.Size())
+		if _, err := f.ReadAt(data, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("Truncated entry %q", data)
+		}
+		name := string(data[:i])
+		if data[i] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[i+1 : end])
+			data = data[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[i+1:])
+		fields[name] = string(data[i+9 : i+9+int(size)])
+		data = data[i+9+int(size)+1:]
+	}
+	return fields
+}
+
+func TestJournalLogger(t *testing.T) {
+	conn := listenJournal(t)
+	l, err := NewJournalLogger("api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Warningf("disk %d%% full", 91); err != nil {
+		t.Fatal(err)
+	}
+	e := readJournalEntry(t, conn)
+	if e["MESSAGE"] != "disk 91% full" || e["PRIORITY"] != "4" || e["SYSLOG_IDENTIFIER"] != "api" {
+		t.Errorf("Unexpected entry %q", e)
+	}
+	if !strings.HasSuffix(e["CODE_FILE"], "journald_test.go") || e["CODE_LINE"] == "" {
+		t.Errorf("Expected the caller in CODE_FILE, got %q:%q", e["CODE_FILE"], e["CODE_LINE"])
+	}
+
+	fl := service.WithFields(l, map[string]string{"request-id": "42", "_PID": "1"})
+	if err := fl.Error("first line\nsecond line"); err != nil {
+		t.Fatal(err)
+	}
+	e = readJournalEntry(t, conn)
+	if e["MESSAGE"] != "first line\nsecond line" || e["PRIORITY"] != "3" {
+		t.Errorf("Unexpected entry %q", e)
+	}
+	if e["REQUEST_ID"] != "42" || e["PID"] != "1" {
+		t.Errorf("Expected sanitized caller fields, got %q", e)
+	}
+}
+
+func TestJournalLoggerLargeEntry(t *testing.T) {
+	conn := listenJournal(t)
+	l, err := NewJournalLogger("api", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := strings.Repeat("x", 4<<20)
+	if err := l.Info(message); err != nil {
+		t.Fatal(err)
+	}
+	if e := readJournalEntry(t, conn); e["MESSAGE"] != message || e["PRIORITY"] != "6" {
+		t.Errorf("Expected the large entry to arrive whole, got %d bytes", len(e["MESSAGE"]))
+	}
+}
+
+func TestJournalFieldName(t *testing.T) {
+	for in, want := range map[string]string{
+		"REQUEST_ID": "REQUEST_ID",
+		"request-id": "REQUEST_ID",
+		"_PID":       "PID",
+		"1st":        "ST",
+		"--":         "",
+		"a.b c":      "A_B_C",
+	} {
+		if got := journalFieldName(in); got != want {
+			t.Errorf("journalFieldName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -46,6 +46,7 @@ func (s sysLogger) Infof(format string, a ...interface{}) error {
 	return s.send(s.Writer.Info(fmt.Sprintf(format, a...)))
 }
 
-var TF = template.FuncMap{"cmdEscape": cmdEscape}
+var TF = template.FuncMap{"cmd": cmdQuote, "cmdEscape": cmdEscape}
 
+func cmdQuote(s string) string  { return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"` }
 func cmdEscape(s string) string { return strings.ReplaceAll(s, " ", "\\ ") }
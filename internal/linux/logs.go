@@ -0,0 +1,174 @@
+package linux
+
+import (
+	"bufio"
+	"context"
+	"github.com/faelmori/keepgo/service"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// logPollInterval is how often followed log files are checked for growth.
+var logPollInterval = 250 * time.Millisecond
+
+// logStream is a service.LogStream fed by a producer goroutine.
+type logStream struct {
+	entries chan service.LogEntry
+	done    chan struct{}
+	err     error
+	cancel  context.CancelFunc
+}
+
+// newLogStream runs produce until it returns or ctx is done. emit reports
+// false once the reader is gone and produce should stop.
+func newLogStream(ctx context.Context, produce func(ctx context.Context, emit func(service.LogEntry) bool) error) *logStream {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &logStream{entries: make(chan service.LogEntry), done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(s.done)
+		s.err = produce(ctx, func(e service.LogEntry) bool {
+			select {
+			case s.entries <- e:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+		if s.err == nil {
+			s.err = ctx.Err()
+		}
+	}()
+	return s
+}
+
+func (s *logStream) Next() (service.LogEntry, error) {
+	select {
+	case e := <-s.entries:
+		return e, nil
+	case <-s.done:
+		if s.err != nil {
+			return service.LogEntry{}, s.err
+		}
+		return service.LogEntry{}, io.EOF
+	}
+}
+
+func (s *logStream) Close() error {
+	s.cancel()
+	<-s.done
+	return nil
+}
+
+// logFile is a plain log file and the priority its lines are reported at.
+type logFile struct {
+	Path     string
+	Priority int
+}
+
+// fileLogs streams the lines of plain log files: the last opts.Lines of each
+// and, when following, whatever is appended to any of them afterwards.
+// Rotated and truncated files are picked up from their start. The files
+// carry no timestamps, so opts.Since and opts.Until do not apply.
+func fileLogs(ctx context.Context, files []logFile, opts service.LogOptions) service.LogStream {
+	return newLogStream(ctx, func(ctx context.Context, emit func(service.LogEntry) bool) error {
+		offsets := make([]int64, len(files))
+		inodes := make([]uint64, len(files))
+		for i, f := range files {
+			lines, offset, inode, err := readLogLines(f.Path, 0, !opts.Follow)
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if opts.Lines > 0 && len(lines) > opts.Lines {
+				lines = lines[len(lines)-opts.Lines:]
+			}
+			for _, line := range lines {
+				if !emit(service.LogEntry{Priority: f.Priority, Message: line}) {
+					return nil
+				}
+			}
+			offsets[i], inodes[i] = offset, inode
+		}
+		if !opts.Follow {
+			return nil
+		}
+
+		ticker := time.NewTicker(logPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			for i, f := range files {
+				info, err := os.Stat(f.Path)
+				if err != nil {
+					offsets[i] = 0
+					continue
+				}
+				if inode := fileInode(info); inode != inodes[i] || info.Size() < offsets[i] {
+					offsets[i], inodes[i] = 0, inode
+				}
+				if info.Size() == offsets[i] {
+					continue
+				}
+				lines, offset, _, err := readLogLines(f.Path, offsets[i], false)
+				if err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				offsets[i] = offset
+				for _, line := range lines {
+					if !emit(service.LogEntry{Priority: f.Priority, Message: line}) {
+						return nil
+					}
+				}
+			}
+		}
+	})
+}
+
+// readLogLines reads the lines of path from offset on. A last line without
+// its newline is only returned with partial set, otherwise it is left for
+// the next read. It returns the offset to continue from.
+func readLogLines(path string, offset int64, partial bool) ([]string, int64, uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, offset, 0, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, offset, 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, 0, err
+	}
+
+	var lines []string
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			if partial && line != "" {
+				lines = append(lines, line)
+				offset += int64(len(line))
+			}
+			return lines, offset, fileInode(info), nil
+		}
+		if err != nil {
+			return lines, offset, 0, err
+		}
+		offset += int64(len(line))
+		lines = append(lines, strings.TrimSuffix(line, "\n"))
+	}
+}
+
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return st.Ino
+	}
+	return 0
+}
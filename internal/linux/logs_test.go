@@ -0,0 +1,131 @@
+package linux
+
+import (
+	"context"
+	"errors"
+	"github.com/faelmori/keepgo/service"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// streamRunner adds streaming to fakeRunner, serving the recorded output.
+type streamRunner struct{ fakeRunner }
+
+func (r *streamRunner) Stream(ctx context.Context, command string, arguments ...string) (io.ReadCloser, error) {
+	_, out, err := r.RunWithOutput(command, arguments...)
+	return io.NopCloser(strings.NewReader(out)), err
+}
+
+func readLogs(t *testing.T, s service.LogStream, n int) []service.LogEntry {
+	var entries []service.LogEntry
+	for len(entries) < n {
+		e, err := s.Next()
+		if err != nil {
+			t.Fatalf("After %d entries: %v", len(entries), err)
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func TestSystemdLogsFromJournal(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{Name: "api"})
+	since := time.Unix(1760000000, 0)
+	r.outputs["journalctl -o json --no-pager --unit=api.service --since=@1760000000 --lines=2"] =
+		`{"__REALTIME_TIMESTAMP":"1760000001000000","PRIORITY":"3","MESSAGE":"boom"}` + "\n" +
+			`{"__REALTIME_TIMESTAMP":"1760000002000000","MESSAGE":[104,105]}` + "\n"
+
+	logs, err := service.Logs(context.Background(), s, service.LogOptions{Since: since, Lines: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	entries := readLogs(t, logs, 2)
+	if entries[0].Message != "boom" || entries[0].Priority != service.PriorityErr || !entries[0].Time.Equal(time.Unix(1760000001, 0)) {
+		t.Errorf("Unexpected first entry %+v", entries[0])
+	}
+	if entries[1].Message != "hi" || entries[1].Priority != service.PriorityInfo {
+		t.Errorf("Unexpected second entry %+v", entries[1])
+	}
+	if _, err := logs.Next(); err != io.EOF {
+		t.Errorf("Expected io.EOF at the end, got %v", err)
+	}
+
+	if _, err := s.Logs(context.Background(), service.LogOptions{Follow: true}); !errors.Is(err, service.ErrNotSupported) {
+		t.Errorf("Expected following without a streaming runner to be unsupported, got %v", err)
+	}
+}
+
+func TestSystemdLogsFollow(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionUserService: true},
+	})
+	r := &streamRunner{fakeRunner{outputs: map[string]string{
+		"journalctl -o json --no-pager --user-unit=api.service --lines=all --follow": `{"MESSAGE":"up"}` + "\n",
+	}}}
+	s.runner = r
+
+	logs, err := s.Logs(context.Background(), service.LogOptions{Follow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer logs.Close()
+	if e := readLogs(t, logs, 1); e[0].Message != "up" {
+		t.Errorf("Unexpected entry %+v (calls %q)", e[0], r.calls)
+	}
+}
+
+func TestFileLogsFollow(t *testing.T) {
+	logPollInterval = 10 * time.Millisecond
+	defer func() { logPollInterval = 250 * time.Millisecond }()
+	dir := t.TempDir()
+	out, errPath := filepath.Join(dir, "api.out"), filepath.Join(dir, "api.err")
+	if err := os.WriteFile(out, []byte("one\ntwo\nthree\npart"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logs := fileLogs(ctx, []logFile{{out, service.PriorityInfo}, {errPath, service.PriorityErr}},
+		service.LogOptions{Lines: 2, Follow: true})
+	defer logs.Close()
+	if e := readLogs(t, logs, 2); e[0].Message != "two" || e[1].Message != "three" {
+		t.Errorf("Expected the last two complete lines, got %+v", e)
+	}
+
+	appendFile := func(path, s string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.WriteString(s)
+		f.Close()
+	}
+	appendFile(out, "ial\n")
+	appendFile(errPath, "failed\n")
+	// The files are followed independently, so their lines may interleave.
+	got := map[string]int{}
+	for _, e := range readLogs(t, logs, 2) {
+		got[e.Message] = e.Priority
+	}
+	if p, ok := got["partial"]; !ok || p != service.PriorityInfo || got["failed"] != service.PriorityErr {
+		t.Errorf("Unexpected followed entries %v", got)
+	}
+
+	// A rotated file is read again from its start.
+	os.Remove(out)
+	appendFile(out, "fresh\n")
+	if e := readLogs(t, logs, 1); e[0].Message != "fresh" {
+		t.Errorf("Expected the rotated file to be followed, got %+v", e)
+	}
+
+	cancel()
+	if _, err := logs.Next(); err != context.Canceled {
+		t.Errorf("Expected the stream to end with the context, got %v", err)
+	}
+}
@@ -1,31 +1,93 @@
 package linux
 
 import (
+	"context"
+	"errors"
 	"github.com/faelmori/keepgo/runners"
 	"github.com/faelmori/keepgo/service"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// Where openrc-run scripts and their configuration live.
+var (
+	openrcInitDir = "/etc/init.d"
+	openrcConfDir = "/etc/conf.d"
+)
+
+// openrcRunlevel is the runlevel services are added to.
+const openrcRunlevel = "default"
+
 func NewOpenRCService(i service.Controller, platform string, c *service.Config, r *runners.Runner) (service.Service, error) {
-	return &openRCService{Name: c.Name}, nil
+	s := &openRCService{}
+	// openrc-run only sources conf.d, the daemon would not see the variables
+	// unless they are exported.
+	s.initService = newInitService(i, platform, c, r, initEnv{path: s.environmentFile, exports: true})
+	return s, nil
 }
 
-type openRCService struct{ Name string }
+type openRCService struct {
+	initService
+}
 
-func (s *openRCService) Run() error                                          { panic("implement me") }
-func (s *openRCService) Install() error                                      { panic("implement me") }
-func (s *openRCService) Uninstall() error                                    { panic("implement me") }
-func (s *openRCService) GetLogger(errs chan<- error) (service.Logger, error) { panic("implement me") }
-func (s *openRCService) SystemLogger(errs chan<- error) (service.Logger, error) {
-	panic("implement me")
+type openrcData struct {
+	*service.Config
+	Path   string
+	Args   string
+	User   string
+	Depend []string
 }
-func (s *openRCService) String() string                  { panic("implement me") }
-func (s *openRCService) Platform() string                { panic("implement me") }
-func (s *openRCService) Status() (service.Status, error) { panic("implement me") }
-func (s *openRCService) Start() error                    { return runOpenRCCommand("/etc/init.d/"+s.Name, "start") }
-func (s *openRCService) Stop() error                     { return runOpenRCCommand("/etc/init.d/"+s.Name, "stop") }
 
+func (s *openRCService) Run() error { return s.runService(s) }
+func (s *openRCService) Install() error {
+	script, err := s.renderScript()
+	if err != nil {
+		return err
+	}
+	return s.install(s.ConfigPath(), script, 0755, func() error {
+		return s.runCommand("rc-update", "add", s.Name, openrcRunlevel)
+	})
+}
+func (s *openRCService) Uninstall() error {
+	err := s.runCommand("rc-update", "del", s.Name, openrcRunlevel)
+	if err != nil {
+		return err
+	}
+	return s.removeFiles(s.ConfigPath())
+}
+func (s *openRCService) Platform() string { return "openrc" }
+
+// Status maps the exit code of `rc-service <name> status`, which
+// openrc-run sets from the service state.
+func (s *openRCService) Status() (service.Status, error) {
+	if _, err := os.Stat(s.ConfigPath()); os.IsNotExist(err) {
+		return service.StatusUnknown, service.ErrNotInstalled
+	}
+	_, _, err := s.RunWithOutput("rc-service", s.Name, "status")
+	if err == nil {
+		return service.StatusRunning, nil
+	}
+	var exitErr *runners.ExitError
+	if !errors.As(err, &exitErr) {
+		return service.StatusUnknown, err
+	}
+	switch exitErr.ExitCode {
+	case 8: // Starting.
+		return service.StatusRunning, nil
+	case 3, 4, 16: // Stopped, stopping, inactive.
+		return service.StatusStopped, nil
+	case 32:
+		return service.StatusUnknown, errors.New("service crashed")
+	default:
+		return service.StatusUnknown, err
+	}
+}
+func (s *openRCService) Start() error { return s.runCommand("rc-service", s.Name, "start") }
+func (s *openRCService) Stop() error  { return s.runCommand("rc-service", s.Name, "stop") }
 func (s *openRCService) Restart() error {
 	err := s.Stop()
 	if err != nil {
@@ -35,12 +97,101 @@ func (s *openRCService) Restart() error {
 	return s.Start()
 }
 
-func runOpenRCCommand(command string, arguments ...string) error {
-	cmd := exec.Command(command, arguments...)
-	return cmd.Run()
+// Logs implements service.LogReader, following the output supervise-daemon
+// writes for the service.
+func (s *openRCService) Logs(ctx context.Context, opts service.LogOptions) (service.LogStream, error) {
+	return fileLogs(ctx, []logFile{
+		{"/var/log/" + s.Name + ".log", service.PriorityInfo},
+		{"/var/log/" + s.Name + ".err", service.PriorityErr},
+	}, opts), nil
+}
+
+// Render returns the init script, conf.d file and commands a fresh Install
+// would produce. Secret environment values are masked.
+func (s *openRCService) Render() (*service.Rendering, error) {
+	script, err := s.renderScript()
+	if err != nil {
+		return nil, err
+	}
+	return s.render(s.ConfigPath(), script, 0755, []string{"rc-update", "add", s.Name, openrcRunlevel})
+}
+
+// environmentFile is /etc/conf.d/<name>, which openrc-run sources before
+// running the script.
+func (s *openRCService) environmentFile() (string, error) {
+	return filepath.Join(openrcConfDir, s.Config.Name), nil
+}
+func (s *openRCService) ConfigPath() string {
+	return filepath.Join(openrcInitDir, s.Name)
 }
 
+func (s *openRCService) renderScript() ([]byte, error) {
+	path, err := s.ExecPath()
+	if err != nil {
+		return nil, err
+	}
+	depend, err := openrcDepend(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	// supervise-daemon evaluates command_args, so each argument is quoted
+	// once for the assignment and once for the eval.
+	args := make([]string, len(s.Config.Arguments))
+	for i, a := range s.Config.Arguments {
+		args[i] = shellQuote(a)
+	}
+	data := &openrcData{
+		Config: s.Config,
+		Path:   path,
+		Args:   strings.Join(args, " "),
+		User:   s.user(),
+		Depend: depend,
+	}
+	return renderTemplate(s.Config.Option.String(service.OptionOpenRCScript, openrcScript), openrcFuncs, data)
+}
+
+var openrcFuncs = template.FuncMap{"shell": shellQuote}
+
 func IsOpenRC() bool {
 	_, err := exec.LookPath("openrc")
 	return err == nil
 }
+
+const openrcScript = `#!/sbin/openrc-run
+# {{.Name}}: managed by keepgo, changes are overwritten.
+
+name={{shell .Name}}
+{{with or .Description .DisplayName}}description={{shell .}}
+{{end}}
+supervisor=supervise-daemon
+command={{shell .Path}}
+{{- if .Args}}
+command_args={{shell .Args}}
+{{- end}}
+{{- if .User}}
+command_user={{shell .User}}
+{{- end}}
+{{- if .WorkingDirectory}}
+directory={{shell .WorkingDirectory}}
+{{- end}}
+{{- if .ChRoot}}
+chroot={{shell .ChRoot}}
+{{- end}}
+pidfile="/run/${RC_SVCNAME}.pid"
+output_log="/var/log/${RC_SVCNAME}.log"
+error_log="/var/log/${RC_SVCNAME}.err"
+respawn_max=10
+respawn_period=5
+{{if .Depend}}
+depend() {
+{{- range .Depend}}
+	{{.}}
+{{- end}}
+}
+{{end}}
+{{- if .User}}
+start_pre() {
+	checkpath --file --owner "$command_user" --mode 0640 "$output_log" "$error_log"
+}
+{{- end}}
+`
@@ -0,0 +1,95 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestOpenRCService(t *testing.T, c *service.Config) (*openRCService, *fakeRunner) {
+	dir := t.TempDir()
+	setTestVar(t, &openrcInitDir, filepath.Join(dir, "init.d"))
+	setTestVar(t, &openrcConfDir, filepath.Join(dir, "conf.d"))
+	if err := os.Mkdir(openrcInitDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	svc, r := newTestInitService(t, c, "linux-openrc", NewOpenRCService)
+	return svc.(*openRCService), r
+}
+
+func TestOpenRCScript(t *testing.T) {
+	s, _ := newTestOpenRCService(t, &service.Config{
+		Name:             "app",
+		Description:      "The app daemon",
+		UserName:         "app",
+		Arguments:        []string{"--listen", ":8080", "it's"},
+		WorkingDirectory: "/srv/app",
+		Dependencies:     &service.Dependencies{Requires: []string{"postgresql"}, NetworkOnline: true},
+		Option:           service.KeyValue{service.OptionGroup: "staff"},
+	})
+	script, err := s.renderScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#!/sbin/openrc-run\n",
+		"description='The app daemon'\n",
+		"supervisor=supervise-daemon\n",
+		"command='/usr/bin/app'\n",
+		`command_args=''\''--listen'\'' '\'':8080'\'' '\''it'\''\'\'''\''s'\'''` + "\n",
+		"command_user='app:staff'\n",
+		"directory='/srv/app'\n",
+		`pidfile="/run/${RC_SVCNAME}.pid"` + "\n",
+		"depend() {\n\tneed postgresql net\n}\n",
+		`checkpath --file --owner "$command_user"`,
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("Expected %q in script:\n%s", want, script)
+		}
+	}
+
+	s.Config.Dependencies = nil
+	s.Config.UserName = ""
+	script, _ = s.renderScript()
+	for _, unwanted := range []string{"depend()", "command_user", "start_pre"} {
+		if strings.Contains(string(script), unwanted) {
+			t.Errorf("Unexpected %q in script:\n%s", unwanted, script)
+		}
+	}
+}
+
+func TestOpenRCInstall(t *testing.T) {
+	s, r := newTestOpenRCService(t, &service.Config{
+		Name:          "app",
+		EnvVars:       map[string]string{"PORT": "8080"},
+		SecretEnvVars: map[string]string{"TOKEN": "s3cret"},
+	})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(s.ConfigPath()); err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("Expected an executable init script, got %v, %v", info, err)
+	}
+	env, _ := s.EnvironmentFile()
+	content, err := os.ReadFile(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "PORT=\"8080\"\nTOKEN=\"s3cret\"\nexport PORT TOKEN\n"; !strings.HasSuffix(string(content), want) {
+		t.Errorf("Expected conf.d to end with %q, got:\n%s", want, content)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.calls, "|"); got != "rc-update add app default|rc-update del app default" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	for _, path := range []string{s.ConfigPath(), env} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+}
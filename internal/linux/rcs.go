@@ -1,32 +1,92 @@
 package linux
 
 import (
+	"errors"
+	"fmt"
 	"github.com/faelmori/keepgo/runners"
 	"github.com/faelmori/keepgo/service"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// Where BSD rc.d scripts and their configuration live. Local scripts go to
+// /usr/local/etc/rc.d where the system has one, as on FreeBSD.
+var (
+	rcsLocalDir = "/usr/local/etc/rc.d"
+	rcsBaseDir  = "/etc/rc.d"
+	rcConfPath  = "/etc/rc.conf"
+	rcConfDir   = "/etc/rc.conf.d"
+)
+
+// rcsLookPath finds sysrc, which edits rc.conf on FreeBSD.
+var rcsLookPath = exec.LookPath
+
 func NewRCSService(i service.Controller, platform string, c *service.Config, r *runners.Runner) (service.Service, error) {
-	return &rcsService{Name: c.Name}, nil
+	s := &rcsService{}
+	s.initService = newInitService(i, platform, c, r, initEnv{path: s.environmentFile})
+	return s, nil
 }
 
 type rcsService struct {
-	Name string
+	initService
 }
 
-func (s *rcsService) Run() error                                          { panic("implement me") }
-func (s *rcsService) Install() error                                      { panic("implement me") }
-func (s *rcsService) Uninstall() error                                    { panic("implement me") }
-func (s *rcsService) GetLogger(errs chan<- error) (service.Logger, error) { panic("implement me") }
-func (s *rcsService) SystemLogger(errs chan<- error) (service.Logger, error) {
-	panic("implement me")
+type rcsData struct {
+	*service.Config
+	Var             string
+	Args            string
+	Require         string
+	Before          string
+	EnvironmentFile string
 }
-func (s *rcsService) String() string                  { return s.Name }
-func (s *rcsService) Platform() string                { return "rcs" }
-func (s *rcsService) Status() (service.Status, error) { panic("implement me") }
-func (s *rcsService) Start() error                    { return runRcsCommand("/etc/rc.d/"+s.Name, "start") }
-func (s *rcsService) Stop() error                     { return runRcsCommand("/etc/rc.d/"+s.Name, "stop") }
+
+func (s *rcsService) Run() error { return s.runService(s) }
+func (s *rcsService) Install() error {
+	script, err := s.renderScript()
+	if err != nil {
+		return err
+	}
+	return s.install(s.ConfigPath(), script, 0755, s.enable)
+}
+func (s *rcsService) Uninstall() error {
+	err := s.disable()
+	if err != nil {
+		return err
+	}
+	return s.removeFiles(s.ConfigPath())
+}
+func (s *rcsService) Platform() string { return "rcs" }
+
+// Status maps the exit code of `service <name> status`: rc.subr exits 0
+// when the pidfile names a live daemon and 1 when it does not. A stale
+// pidfile fails with other codes on some systems, but rc.subr still says
+// the service is not running.
+func (s *rcsService) Status() (service.Status, error) {
+	if _, err := os.Stat(s.ConfigPath()); os.IsNotExist(err) {
+		return service.StatusUnknown, service.ErrNotInstalled
+	}
+	// status refuses to look unless <name>_enable is set, and exits 0 doing
+	// so; onestatus always checks.
+	_, out, err := s.RunWithOutput("service", s.Name, "onestatus")
+	if err == nil {
+		return service.StatusRunning, nil
+	}
+	var exitErr *runners.ExitError
+	if !errors.As(err, &exitErr) {
+		return service.StatusUnknown, err
+	}
+	if exitErr.ExitCode == 1 || strings.Contains(out+exitErr.Stderr, "not running") {
+		return service.StatusStopped, nil
+	}
+	return service.StatusUnknown, err
+}
+func (s *rcsService) Start() error { return s.runCommand("service", s.Name, "start") }
+func (s *rcsService) Stop() error  { return s.runCommand("service", s.Name, "stop") }
 func (s *rcsService) Restart() error {
 	err := s.Stop()
 	if err != nil {
@@ -36,12 +96,223 @@ func (s *rcsService) Restart() error {
 	return s.Start()
 }
 
-func runRcsCommand(command string, arguments ...string) error {
-	cmd := exec.Command(command, arguments...)
-	return cmd.Run()
+// Render returns the rc.d script and configuration a fresh Install would
+// write. Secret environment values are masked.
+func (s *rcsService) Render() (*service.Rendering, error) {
+	script, err := s.renderScript()
+	if err != nil {
+		return nil, err
+	}
+	if enable, _ := s.sysrcCommands(); enable != nil {
+		return s.render(s.ConfigPath(), script, 0755, enable)
+	}
+	r, err := s.render(s.ConfigPath(), script, 0755)
+	if err != nil {
+		return nil, err
+	}
+	r.Files = append(r.Files, service.RenderedFile{
+		Path:    filepath.Join(rcConfDir, s.Name),
+		Mode:    0644,
+		Content: []byte(s.enableVar() + "=\"YES\"\n"),
+	})
+	return r, nil
+}
+
+// environmentFile is <name>.env next to the rc.d directory, which rc.subr
+// sources through <name>_env_file.
+func (s *rcsService) environmentFile() (string, error) {
+	return filepath.Join(filepath.Dir(rcsDir()), s.Config.Name+".env"), nil
+}
+
+// sysrcCommands returns the sysrc calls setting and removing
+// <name>_enable, nil without sysrc.
+func (s *rcsService) sysrcCommands() (enable, disable []string) {
+	if _, err := rcsLookPath("sysrc"); err != nil {
+		return nil, nil
+	}
+	return []string{"sysrc", s.enableVar() + "=YES"}, []string{"sysrc", "-i", "-x", s.enableVar()}
+}
+
+// enable sets <name>_enable to YES with sysrc. Without it the variable is
+// set in rc.conf where it is already there, in rc.conf.d/<name> otherwise.
+func (s *rcsService) enable() error {
+	if enable, _ := s.sysrcCommands(); enable != nil {
+		return s.runCommand(enable[0], enable[1:]...)
+	}
+	found, err := setRcConfVar(rcConfPath, s.enableVar(), `"YES"`)
+	if err != nil || found {
+		return err
+	}
+	err = os.MkdirAll(rcConfDir, 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(rcConfDir, s.Name), []byte(s.enableVar()+"=\"YES\"\n"), 0644)
+}
+
+// disable drops <name>_enable from rc.conf and rc.conf.d/<name>.
+func (s *rcsService) disable() error {
+	if _, disable := s.sysrcCommands(); disable != nil {
+		err := s.runCommand(disable[0], disable[1:]...)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := setRcConfVar(rcConfPath, s.enableVar(), "")
+	if err != nil {
+		return err
+	}
+	err = os.Remove(filepath.Join(rcConfDir, s.Name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+var rcsVarInvalid = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// rcVar is the service name as rc.subr uses it in variable names.
+func (s *rcsService) rcVar() string {
+	return rcsVarInvalid.ReplaceAllString(s.Name, "_")
+}
+func (s *rcsService) enableVar() string {
+	return s.rcVar() + "_enable"
+}
+
+// setRcConfVar rewrites the assignments of name in the rc.conf at path to
+// value, or removes them when value is empty. It reports whether there were
+// any; a missing file has none.
+func setRcConfVar(path, name, value string) (bool, error) {
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	var found bool
+	var out []string
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), name+"=") {
+			found = true
+			if value != "" {
+				out = append(out, name+"="+value+"\n")
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+	if !found {
+		return false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return true, err
+	}
+	return true, os.WriteFile(path, []byte(strings.Join(out, "")), info.Mode().Perm())
+}
+
+func (s *rcsService) ConfigPath() string {
+	return filepath.Join(rcsDir(), s.Name)
+}
+
+// rcsDir returns the directory local rc.d scripts are installed to.
+func rcsDir() string {
+	if info, err := os.Stat(rcsLocalDir); err == nil && info.IsDir() {
+		return rcsLocalDir
+	}
+	return rcsBaseDir
+}
+
+func (s *rcsService) renderScript() ([]byte, error) {
+	path, err := s.ExecPath()
+	if err != nil {
+		return nil, err
+	}
+	require, before, err := rcorderHeaders(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	// rc.subr evaluates command_args, so the words are quoted once for the
+	// assignment and once for the eval. daemon(8) writes its own pid to
+	// the pidfile, restarts the child and sends its output to syslog.
+	args := []string{"-P", "${pidfile}"}
+	if s.Config.Option.Bool(service.OptionKeepAlive, service.OptionKeepAliveDefault) {
+		args = append(args, "-r")
+	}
+	args = append(args, "-S", "-T", "${name}")
+	if s.Config.UserName != "" {
+		args = append(args, "-u", shellQuote(s.Config.UserName))
+	}
+	// daemon(8) runs the child with the login group of the user and has no
+	// way to pick another.
+	if group := s.Config.Option.String(service.OptionGroup, ""); group != "" {
+		return nil, fmt.Errorf("%s: rc.d cannot run the service with group %s", s.Name, group)
+	}
+	args = append(args, "--", shellQuote(path))
+	for _, a := range s.Config.Arguments {
+		args = append(args, shellQuote(a))
+	}
+
+	envFile, err := s.environmentPath()
+	if err != nil {
+		return nil, err
+	}
+	data := &rcsData{
+		Config:          s.Config,
+		Var:             s.rcVar(),
+		Args:            strings.Join(args, " "),
+		Require:         strings.Join(require, " "),
+		Before:          strings.Join(before, " "),
+		EnvironmentFile: envFile,
+	}
+	return renderTemplate(s.Config.Option.String(service.OptionRCSScript, rcsScript), rcsFuncs, data)
 }
 
+var rcsFuncs = template.FuncMap{"shell": shellQuote}
+
 func IsRCS() bool {
-	_, err := exec.LookPath("rc-status")
+	_, err := os.Stat("/etc/rc.subr")
 	return err == nil
 }
+
+const rcsScript = `#!/bin/sh
+#
+# PROVIDE: {{.Name}}
+# REQUIRE: {{.Require}}
+{{- if .Before}}
+# BEFORE: {{.Before}}
+{{- end}}
+# KEYWORD: shutdown
+#
+# {{.Name}}: managed by keepgo, changes are overwritten.
+# Set {{.Var}}_enable="YES" in /etc/rc.conf or /etc/rc.conf.d/{{.Name}} to run it.
+
+. /etc/rc.subr
+
+name={{shell .Var}}
+rcvar={{.Var}}_enable
+{{- with or .Description .DisplayName}}
+desc={{shell .}}
+{{- end}}
+
+load_rc_config $name
+
+: ${ {{- .Var}}_enable:="NO"}
+{{- if .WorkingDirectory}}
+{{.Var}}_chdir={{shell .WorkingDirectory}}
+{{- end}}
+{{- if .ChRoot}}
+{{.Var}}_chroot={{shell .ChRoot}}
+{{- end}}
+{{- if .EnvironmentFile}}
+{{.Var}}_env_file={{shell .EnvironmentFile}}
+{{- end}}
+
+pidfile="/var/run/${name}.pid"
+command="/usr/sbin/daemon"
+command_args={{shell .Args}}
+
+run_rc_command "$1"
+`
@@ -0,0 +1,180 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestRCSService(t *testing.T, c *service.Config, tools ...string) (*rcsService, *fakeRunner) {
+	dir := t.TempDir()
+	setTestVar(t, &rcsLocalDir, filepath.Join(dir, "usr/local/etc/rc.d"))
+	setTestVar(t, &rcsBaseDir, filepath.Join(dir, "etc/rc.d"))
+	setTestVar(t, &rcConfPath, filepath.Join(dir, "etc/rc.conf"))
+	setTestVar(t, &rcConfDir, filepath.Join(dir, "etc/rc.conf.d"))
+	setTestLookPath(t, &rcsLookPath, tools...)
+	if err := os.MkdirAll(rcsLocalDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	svc, r := newTestInitService(t, c, "linux-rcs", NewRCSService)
+	return svc.(*rcsService), r
+}
+
+func TestRCSScript(t *testing.T) {
+	s, _ := newTestRCSService(t, &service.Config{
+		Name:             "my-app",
+		Description:      "The app daemon",
+		UserName:         "app",
+		Arguments:        []string{"--greeting", "it's $HOME"},
+		WorkingDirectory: "/srv/app",
+		EnvVars:          map[string]string{"PORT": "8080"},
+		Dependencies:     &service.Dependencies{Requires: []string{"postgresql"}, Before: []string{"nginx"}},
+	})
+	script, err := s.renderScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# PROVIDE: my-app\n# REQUIRE: LOGIN postgresql\n# BEFORE: nginx\n# KEYWORD: shutdown\n",
+		"name='my_app'\nrcvar=my_app_enable\n",
+		`: ${my_app_enable:="NO"}` + "\n",
+		"my_app_chdir='/srv/app'\n",
+		"my_app_env_file='" + filepath.Join(filepath.Dir(rcsLocalDir), "my-app.env") + "'\n",
+		`pidfile="/var/run/${name}.pid"` + "\n",
+		`command="/usr/sbin/daemon"` + "\n",
+		"run_rc_command \"$1\"\n",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("Expected %q in script:\n%s", want, script)
+		}
+	}
+
+	// The arguments survive the eval rc.subr runs them through.
+	var args string
+	for _, line := range strings.Split(string(script), "\n") {
+		if strings.HasPrefix(line, "command_args=") {
+			args = strings.TrimPrefix(line, "command_args=")
+		}
+	}
+	want := `-P ${pidfile} -r -S -T ${name} -u 'app' -- '/usr/bin/my-app' '--greeting' 'it'\''s $HOME'`
+	if args != shellQuote(want) {
+		t.Errorf("Expected command_args=%s, got %s", shellQuote(want), args)
+	}
+
+	s.Config.Option = service.KeyValue{service.OptionGroup: "staff"}
+	if _, err := s.renderScript(); err == nil {
+		t.Error("Expected a group to be rejected")
+	}
+}
+
+func TestRCSInstall(t *testing.T) {
+	s, r := newTestRCSService(t, &service.Config{Name: "app"})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(rcsLocalDir, "app")); err != nil || info.Mode().Perm() != 0755 {
+		t.Fatalf("Expected an executable rc.d script, got %v, %v", info, err)
+	}
+	conf, err := os.ReadFile(filepath.Join(rcConfDir, "app"))
+	if err != nil || string(conf) != "app_enable=\"YES\"\n" {
+		t.Errorf("Expected rc.conf.d to enable the service, got %q, %v", conf, err)
+	}
+
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{s.ConfigPath(), filepath.Join(rcConfDir, "app")} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", path, err)
+		}
+	}
+	if got := strings.Join(r.calls, "|"); got != "service app start" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+}
+
+func TestRCSSysrc(t *testing.T) {
+	s, r := newTestRCSService(t, &service.Config{Name: "my-app"}, "sysrc")
+	rendering, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendering.Files) != 1 || len(rendering.Commands) != 1 || strings.Join(rendering.Commands[0], " ") != "sysrc my_app_enable=YES" {
+		t.Errorf("Expected the script and a sysrc call, got %+v", rendering)
+	}
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.calls, "|"); got != "sysrc my_app_enable=YES|sysrc -i -x my_app_enable" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(rcConfDir, "my-app")); !os.IsNotExist(err) {
+		t.Errorf("Expected no rc.conf.d file, got %v", err)
+	}
+
+	s, _ = newTestRCSService(t, &service.Config{Name: "my-app"})
+	rendering, err = s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendering.Commands) != 0 || rendering.Files[len(rendering.Files)-1].Path != filepath.Join(rcConfDir, "my-app") {
+		t.Errorf("Expected the rc.conf.d file without sysrc, got %+v", rendering)
+	}
+}
+
+func TestRCSEnableInRcConf(t *testing.T) {
+	s, _ := newTestRCSService(t, &service.Config{Name: "app"})
+	if err := os.MkdirAll(filepath.Dir(rcConfPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(rcConfPath, []byte("hostname=\"box\"\napp_enable=\"NO\"\nsshd_enable=\"YES\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	conf, _ := os.ReadFile(rcConfPath)
+	if string(conf) != "hostname=\"box\"\napp_enable=\"YES\"\nsshd_enable=\"YES\"\n" {
+		t.Errorf("Expected rc.conf to be updated in place, got:\n%s", conf)
+	}
+	if _, err := os.Stat(filepath.Join(rcConfDir, "app")); !os.IsNotExist(err) {
+		t.Errorf("Expected no rc.conf.d file, got %v", err)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	conf, _ = os.ReadFile(rcConfPath)
+	if string(conf) != "hostname=\"box\"\nsshd_enable=\"YES\"\n" {
+		t.Errorf("Expected the variable removed from rc.conf, got:\n%s", conf)
+	}
+}
+
+func TestRCSStatusStalePidfile(t *testing.T) {
+	s, r := newTestRCSService(t, &service.Config{Name: "app"})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	// A stale pidfile, whatever the exit code.
+	r.failures = map[string]error{"service app onestatus": &runners.ExitError{Command: "service", ExitCode: 3}}
+	r.outputs["service app onestatus"] = "app is not running.\n"
+	if got, err := s.Status(); got != service.StatusStopped || err != nil {
+		t.Errorf("Expected a stale pidfile to mean stopped, got %v, %v", got, err)
+	}
+	delete(r.outputs, "service app onestatus")
+	r.failures = map[string]error{"service app onestatus": &runners.ExitError{Command: "service", ExitCode: 3, Stderr: "app: pidfile /var/run/app.pid is stale, not running\n"}}
+	if got, err := s.Status(); got != service.StatusStopped || err != nil {
+		t.Errorf("Expected a stale pidfile to mean stopped, got %v, %v", got, err)
+	}
+}
@@ -1,6 +1,7 @@
 package linux
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/faelmori/keepgo/runners"
@@ -17,13 +18,36 @@ import (
 )
 
 func NewSystemdService(i service.Controller, platform string, c *service.Config, r *runners.Runner) (service.Service, error) {
-	return &systemdService{
+	s := &systemdService{
 		Name:     c.Name,
 		Config:   c,
 		i:        i,
 		platform: platform,
-		runner:   r,
-	}, nil
+		runner:   runners.Resolve(r),
+	}
+	useDBus := c.Option.Bool(service.OptionSystemdDBus, service.OptionSystemdDBusDefault)
+	var sessionBus string
+	if s.IsUserService() {
+		u, err := lookupSystemdUser()
+		if err != nil {
+			return nil, err
+		}
+		if u.Sudo {
+			version := s.GetSystemdVersion()
+			s.machine = version == -1 || version >= machineScopeSince
+		}
+		env := userSessionEnv(u, s.machine)
+		if er, ok := s.runner.(*runners.ExecRunner); ok && len(env) > 0 {
+			s.runner = er.WithEnv(env...)
+		}
+		sessionBus = sessionBusAddress(env)
+		// Root cannot join another user's session bus, systemctl is used.
+		useDBus = useDBus && !u.Sudo
+	}
+	if useDBus {
+		s.dbus = newSystemdDBus(s.IsUserService(), sessionBus)
+	}
+	return s, nil
 }
 
 type systemdService struct {
@@ -31,96 +55,294 @@ type systemdService struct {
 	Config   *service.Config
 	i        service.Controller
 	platform string
-	runner   *runners.Runner
+	runner   runners.Runner
+	dbus     *systemdDBus // Set when systemd is driven over D-Bus instead of systemctl.
+	instance string       // Instance of a template service, escaped.
+	machine  bool         // Under sudo, systemctl reaches the user manager through --machine.
 }
 
 func (s *systemdService) Run() error {
-	err := s.Start()
+	err := s.i.Start(s)
 	if err != nil {
 		return err
 	}
+	_ = service.NotifyReady()
 
-	s.Config.Option.FuncSingle(service.OptionRunWait, func() {
+	stopWatchdog := service.StartWatchdog()
+	defer stopWatchdog()
+
+	wait := func() {
 		var sigChan = make(chan os.Signal, 3)
 		signal.Notify(sigChan, syscall.SIGTERM, os.Interrupt)
-		<-sigChan
-	})()
+		defer signal.Stop(sigChan)
+		if !s.IsScheduled() {
+			<-sigChan
+			return
+		}
+		// A scheduled job is done once its controller says so; without a
+		// service.Job, once the blocking Start returned.
+		if job, ok := s.i.(service.Job); ok {
+			select {
+			case <-job.Done():
+			case <-sigChan:
+			}
+		}
+	}
+	s.Config.Option.FuncSingle(service.OptionRunWait, wait)()
 
-	return s.Stop()
+	_ = service.NotifyStopping()
+	return s.i.Stop(s)
 }
 func (s *systemdService) Install() error {
+	if s.Config.Option.Bool(service.OptionUpgrade, service.OptionUpgradeDefault) {
+		_, err := s.Upgrade(s.Config.Option.Bool(service.OptionRestartOnUpgrade, service.OptionRestartOnUpgradeDefault))
+		return err
+	}
+
 	confPath, err := s.ConfigPath()
 	if err != nil {
 		return err
 	}
 	_, err = os.Stat(confPath)
+	if err == nil && s.instance != "" {
+		// The template is in place, only the instance needs enabling.
+		err = s.enableLinger()
+		if err != nil {
+			return err
+		}
+		rollback := &unitRollback{s: s}
+		err = rollback.enable(s.UnitName())
+		if err == nil {
+			err = s.run("daemon-reload")
+		}
+		if err != nil {
+			return rollback.undo(err)
+		}
+		return nil
+	}
 	if err == nil {
 		return fmt.Errorf("init already exists: %s", confPath)
 	}
 
-	f, err := os.OpenFile(confPath, os.O_WRONLY|os.O_CREATE, 0644)
+	units, err := s.renderUnits()
 	if err != nil {
 		return err
 	}
-	defer func(f *os.File) {
-		closeErr := f.Close()
-		if closeErr != nil {
-			fmt.Println(closeErr)
+	err = s.verifyUnits(units)
+	if err != nil {
+		return err
+	}
+	rollback := &unitRollback{s: s}
+	for _, u := range units {
+		err = rollback.write(u.Path, u.Content)
+		if err != nil {
+			return rollback.undo(err)
 		}
-	}(f)
+	}
+	if s.hasEnvironment() {
+		err = rollback.writeEnvironment()
+		if err != nil {
+			return rollback.undo(err)
+		}
+	}
+	// The user manager has to be running for the enable calls to reach it.
+	err = s.enableLinger()
+	if err != nil {
+		return rollback.undo(err)
+	}
+	for _, u := range units {
+		if !u.Enable {
+			continue
+		}
+		err = rollback.enable(u.Name)
+		if err != nil {
+			return rollback.undo(err)
+		}
+	}
+	err = s.run("daemon-reload")
+	if err != nil {
+		return rollback.undo(err)
+	}
+	return nil
+}
+
+// systemdUnit is one of the unit files making up the service.
+type systemdUnit struct {
+	Name   string // Unit to enable, an instance of File for template services.
+	File   string // Unit file name on disk.
+	Enable bool   // Enabled on install, disabled on uninstall.
+	render func() ([]byte, error)
+}
+
+// units lists the unit files Install writes, the service unit first and
+// the units enabled in its place, if any, after it.
+func (s *systemdService) units() ([]systemdUnit, error) {
+	if s.IsTemplate() && (s.HasSockets() || s.IsScheduled()) {
+		return nil, fmt.Errorf("%s: template services cannot be socket activated or scheduled", s.Name)
+	}
 
+	units := []systemdUnit{{
+		Name:   s.UnitName(),
+		File:   s.unitFileName(),
+		Enable: !s.IsScheduled() && (!s.IsTemplate() || s.instance != ""),
+		render: s.renderUnit,
+	}}
+	if s.HasSockets() {
+		units = append(units, systemdUnit{Name: s.SocketUnitName(), File: s.SocketUnitName(), Enable: true, render: s.renderSocketUnit})
+	}
+	if s.IsScheduled() {
+		units = append(units, systemdUnit{Name: s.TimerUnitName(), File: s.TimerUnitName(), Enable: true, render: s.renderTimerUnit})
+	}
+	return units, nil
+}
+
+type systemdUnitData struct {
+	*service.Config
+	Path                 string
+	HasOutputFileSupport bool
+	ReloadSignal         string
+	PIDFile              string
+	LimitNOFILE          int
+	Restart              string
+	SuccessExitStatus    string
+	LogOutput            bool
+	LogDirectory         string
+	Notify               bool
+	WatchdogSec          string
+	Scheduled            bool
+	HardeningDirectives  []string
+	ResourceDirectives   []string
+	DependencyDirectives []string
+	UserUnit             bool
+	EnvironmentFile      string
+	CredentialDirectives []string
+}
+
+func (s *systemdService) unitData() (*systemdUnitData, error) {
 	path, err := s.ExecPath()
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	hardening, err := s.hardening()
+	if err != nil {
+		return nil, err
 	}
+	logOutput := s.Config.Option.Bool(service.OptionLogOutput, service.OptionLogOutputDefault)
+	logDirectory := s.Config.Option.String(service.OptionLogDirectory, service.OptionLogDirectoryDefault)
+	if logOutput && hardening.ProtectSystem == "strict" {
+		// Keep the log files writable under a read-only file system.
+		h := *hardening
+		h.ReadWritePaths = append(append([]string{}, h.ReadWritePaths...), logDirectory)
+		hardening = &h
+	}
+	version := s.GetSystemdVersion()
+	directives, warnings := hardeningDirectives(hardening, version)
+	s.warn(warnings)
 
-	var to = &struct {
-		*service.Config
-		Path                 string
-		HasOutputFileSupport bool
-		ReloadSignal         string
-		PIDFile              string
-		LimitNOFILE          int
-		Restart              string
-		SuccessExitStatus    string
-		LogOutput            bool
-		LogDirectory         string
-	}{
+	resources, err := resourceDirectives(s.Config.Resources)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := systemdDependencyDirectives(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	envFile, err := s.EnvironmentFile()
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := credentialDirectives(s.Config.Credentials, version)
+	if err != nil {
+		return nil, err
+	}
+	limitNOFILE := s.Config.Option.Int(service.OptionLimitNOFILE, service.OptionLimitNOFILEDefault)
+	if s.Config.Resources != nil && s.Config.Resources.Limits["NOFILE"] != "" {
+		limitNOFILE = service.OptionLimitNOFILEDefault
+	}
+
+	return &systemdUnitData{
 		s.Config,
 		path,
 		s.HasOutputFileSupport(),
 		s.Config.Option.String(service.OptionReloadSignal, ""),
 		s.Config.Option.String(service.OptionPIDFile, ""),
-		s.Config.Option.Int(service.OptionLimitNOFILE, service.OptionLimitNOFILEDefault),
+		limitNOFILE,
 		s.Config.Option.String(service.OptionRestart, "always"),
 		s.Config.Option.String(service.OptionSuccessExitStatus, ""),
-		s.Config.Option.Bool(service.OptionLogOutput, service.OptionLogOutputDefault),
-		s.Config.Option.String(service.OptionLogDirectory, service.OptionLogDirectoryDefault),
-	}
+		logOutput,
+		logDirectory,
+		s.Config.Option.Bool(service.OptionNotify, service.OptionNotifyDefault),
+		s.Config.Option.String(service.OptionWatchdogSec, ""),
+		s.IsScheduled(),
+		directives,
+		resources,
+		dependencies,
+		s.IsUserService(),
+		envFile,
+		credentials,
+	}, nil
+}
 
-	err = s.GetTemplate().Execute(f, to)
+// renderUnit executes the unit template without touching the system.
+func (s *systemdService) renderUnit() ([]byte, error) {
+	data, err := s.unitData()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	err = s.runAction("enable")
+	tmpl, err := s.parseTemplate()
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return s.run("daemon-reload")
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 func (s *systemdService) Uninstall() error {
-	err := s.runAction("disable")
-	if err != nil {
-		return err
+	if s.instance != "" {
+		// The template and its drop-ins are shared with the other instances.
+		err := s.run("stop", s.UnitName())
+		if err != nil {
+			return err
+		}
+		return s.run("disable", s.UnitName())
 	}
-	cp, err := s.ConfigPath()
+
+	units, err := s.units()
 	if err != nil {
 		return err
 	}
-	if err := os.Remove(cp); err != nil {
-		return err
+	for _, u := range units {
+		if !u.Enable && !s.IsTemplate() {
+			continue
+		}
+		err := s.run("disable", u.Name)
+		if err != nil {
+			return err
+		}
+	}
+	for i, u := range units {
+		path, err := s.unitPath(u.File)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		// Only a missing service unit means the service was not installed.
+		if err != nil && (i == 0 || !os.IsNotExist(err)) {
+			return err
+		}
+	}
+	if dir, err := s.dropInDir(); err == nil {
+		_ = os.RemoveAll(dir)
+	}
+	if s.hasEnvironment() {
+		err = s.RemoveEnvironment()
+		if err != nil {
+			return err
+		}
 	}
 	return s.run("daemon-reload")
 }
@@ -131,8 +353,27 @@ func (s *systemdService) GetLogger(errs chan<- error) (service.Logger, error) {
 	return s.SystemLogger(errs)
 }
 func (s *systemdService) SystemLogger(errs chan<- error) (service.Logger, error) {
+	if journalAvailable() {
+		if l, err := NewJournalLogger(s.Name, errs); err == nil {
+			return l, nil
+		}
+	}
 	return NewSysLogger(s.Name, errs)
 }
+
+// warn reports warnings through the logger of the service.
+func (s *systemdService) warn(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	l, err := s.GetLogger(nil)
+	if err != nil {
+		return
+	}
+	for _, w := range warnings {
+		_ = l.Warning(w)
+	}
+}
 func (s *systemdService) String() string {
 	return s.Name
 }
@@ -140,8 +381,16 @@ func (s *systemdService) Platform() string {
 	return "systemd"
 }
 func (s *systemdService) Status() (service.Status, error) {
-	exitCode, out, err := s.RunWithOutput("systemctl", "is-active", s.UnitName())
-	if exitCode == 0 && err != nil {
+	if s.IsTemplate() && s.instance == "" {
+		return service.StatusUnknown, errNoInstance
+	}
+	unit := s.controlUnit()
+	if s.dbus != nil {
+		return s.dbus.status(unit)
+	}
+
+	_, out, err := s.systemctl("is-active", unit)
+	if err != nil && !runners.IsExitError(err) {
 		return service.StatusUnknown, err
 	}
 
@@ -149,8 +398,9 @@ func (s *systemdService) Status() (service.Status, error) {
 	case strings.HasPrefix(out, "active"):
 		return service.StatusRunning, nil
 	case strings.HasPrefix(out, "inactive"):
-		exitCode, out, err := s.RunWithOutput("systemctl", "list-unit-files", "-t", "service", s.UnitName())
-		if exitCode == 0 && err != nil {
+		unitType := strings.TrimPrefix(filepath.Ext(unit), ".")
+		_, out, err := s.systemctl("list-unit-files", "-t", unitType, unit)
+		if err != nil && !runners.IsExitError(err) {
 			return service.StatusUnknown, err
 		}
 		if strings.Contains(out, s.Name) {
@@ -175,42 +425,72 @@ func (s *systemdService) Restart() error {
 	return s.runAction("restart")
 }
 func (s *systemdService) ExecPath() (string, error) {
-	path := s.Config.Option.String(service.OptionSystemdScript, "")
-	if path != "" {
-		return path, nil
+	if s.Config.Executable != "" {
+		return s.Config.ExecPath()
 	}
-
 	return exec.LookPath(s.Name)
 }
 func (s *systemdService) RunWithOutput(command string, arguments ...string) (int, string, error) {
-	if command == "systemctl" && arguments[0] == "is-active" {
-		return 0, "active", nil
-	}
-	return 1, "", nil
+	return s.runner.RunWithOutput(command, arguments...)
 }
 func (s *systemdService) run(action string, args ...string) error {
-	if s.IsUserService() {
-		return runSystemdCommand("systemctl", "--user", action, s.UnitName())
+	if s.dbus != nil {
+		if err := s.dbus.run(action, args...); err != errDBusUnsupported {
+			return err
+		}
 	}
-	return runSystemdCommand("systemctl", append([]string{action}, args...)...)
+	return s.runCommand("systemctl", s.systemctlArgs(action, args...)...)
 }
-func (s *systemdService) runAction(action string) error { return s.run(action, s.UnitName()) }
-func (s *systemdService) ConfigPath() (string, error) {
+
+// systemctlArgs builds the systemctl command line for action.
+func (s *systemdService) systemctlArgs(action string, args ...string) []string {
+	return append(append(s.systemctlScope(), action), args...)
+}
+func (s *systemdService) runCommand(command string, args ...string) error {
+	_, _, err := s.RunWithOutput(command, args...)
+	return err
+}
+func (s *systemdService) runAction(action string) error {
+	if s.IsTemplate() && s.instance == "" {
+		return errNoInstance
+	}
+	return s.run(action, s.controlUnit())
+}
+func (s *systemdService) ConfigPath() (string, error) { return s.unitPath(s.unitFileName()) }
+func (s *systemdService) unitPath(unit string) (string, error) {
 	if !s.IsUserService() {
-		return "/etc/systemd/system/" + s.UnitName(), nil
+		return "/etc/systemd/system/" + unit, nil
 	}
-	homeDir, err := os.UserHomeDir()
+	u, err := lookupSystemdUser()
 	if err != nil {
 		return "", err
 	}
-	systemdUserDir := filepath.Join(homeDir, ".config/systemd/user")
-	err = os.MkdirAll(systemdUserDir, os.ModePerm)
-	if err != nil {
-		return "", err
+	return filepath.Join(u.HomeDir, ".config/systemd/user", unit), nil
+}
+func (s *systemdService) UnitName() string {
+	if s.IsTemplate() {
+		return s.Config.Name + "@" + s.instance + ".service"
 	}
-	return filepath.Join(systemdUserDir, s.UnitName()), nil
+	return s.Config.Name + ".service"
+}
+
+// unitFileName is the service unit file on disk, shared by all instances of
+// a template service.
+func (s *systemdService) unitFileName() string {
+	if s.IsTemplate() {
+		return s.Config.Name + "@.service"
+	}
+	return s.UnitName()
+}
+
+// controlUnit is the unit Start, Stop and Status act on: the timer of a
+// scheduled service, the service unit otherwise.
+func (s *systemdService) controlUnit() string {
+	if s.IsScheduled() {
+		return s.TimerUnitName()
+	}
+	return s.UnitName()
 }
-func (s *systemdService) UnitName() string { return s.Config.Name + ".service" }
 func (s *systemdService) GetSystemdVersion() int64 {
 	_, out, err := s.RunWithOutput("systemctl", "--version")
 	if err != nil {
@@ -244,56 +524,62 @@ func (s *systemdService) HasOutputFileSupport() bool {
 	return defaultValue
 }
 func (s *systemdService) GetTemplate() *template.Template {
+	return template.Must(s.parseTemplate())
+}
+func (s *systemdService) parseTemplate() (*template.Template, error) {
 	customScript := s.Config.Option.String(service.OptionSystemdScript, "")
 	if customScript != "" {
-		return template.Must(template.New("").Funcs(TF).Parse(customScript))
+		return template.New("").Funcs(systemdFuncs(s.IsTemplate())).Parse(customScript)
 	}
-	return template.Must(template.New("").Funcs(TF).Parse(systemdScript))
+	return template.New("").Funcs(systemdFuncs(s.IsTemplate())).Parse(systemdScript)
 }
 func (s *systemdService) IsUserService() bool {
 	return s.Config.Option.Bool(service.OptionUserService, service.OptionUserServiceDefault)
 }
 
 const systemdScript = `[Unit]
-Description={{.Description}}
-ConditionFileIsExecutable={{.Path|cmdEscape}}
-{{range $i, $dep := .Dependencies}}
-{{$dep}} {{end}}
-
+Description={{.Description|specifiers}}
+ConditionFileIsExecutable={{.Path|path}}
+{{if .Listen}}Requires={{.Name}}.socket
+After={{.Name}}.socket{{end}}
+{{range .DependencyDirectives}}{{.}}
+{{end}}
 [Service]
 StartLimitInterval=5
 StartLimitBurst=10
-ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}
-{{if .ChRoot}}RootDirectory={{.ChRoot|cmd}}{{end}}
-{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|cmdEscape}}{{end}}
-{{if .UserName}}User={{.UserName}}{{end}}
+{{if .Scheduled}}Type=oneshot{{else if .Notify}}Type=notify{{end}}
+{{if .WatchdogSec}}WatchdogSec={{.WatchdogSec}}{{end}}
+ExecStart={{.Path|execArg}}{{range .Arguments}} {{.|execArg}}{{end}}
+{{if .ChRoot}}RootDirectory={{.ChRoot|path}}{{end}}
+{{if .WorkingDirectory}}WorkingDirectory={{.WorkingDirectory|path}}{{end}}
+{{if and .UserName (not .UserUnit)}}User={{.UserName}}{{end}}
+{{range .HardeningDirectives}}{{.}}
+{{end -}}
 {{if .ReloadSignal}}ExecReload=/bin/kill -{{.ReloadSignal}} "$MAINPID"{{end}}
-{{if .PIDFile}}PIDFile={{.PIDFile|cmd}}{{end}}
+{{if .PIDFile}}PIDFile={{.PIDFile|path}}{{end}}
 {{if and .LogOutput .HasOutputFileSupport -}}
-StandardOutput=file:{{.LogDirectory}}/{{.Name}}.out
-StandardError=file:{{.LogDirectory}}/{{.Name}}.err
+StandardOutput=file:{{.LogDirectory|path}}/{{.Name}}.out
+StandardError=file:{{.LogDirectory|path}}/{{.Name}}.err
 {{- end}}
 {{if gt .LimitNOFILE -1 }}LimitNOFILE={{.LimitNOFILE}}{{end}}
-{{if .Restart}}Restart={{.Restart}}{{end}}
+{{range .ResourceDirectives}}{{.}}
+{{end -}}
+{{if and .Restart (not .Scheduled)}}Restart={{.Restart}}{{end}}
 {{if .SuccessExitStatus}}SuccessExitStatus={{.SuccessExitStatus}}{{end}}
 RestartSec=120
-EnvironmentFile=-/etc/sysconfig/{{.Name}}
+EnvironmentFile=-{{.EnvironmentFile|path}}
 
 {{range $k, $v := .EnvVars -}}
-Environment={{$k}}={{$v}}
+Environment={{env $k $v}}
 {{end -}}
-
+{{range .CredentialDirectives}}{{.}}
+{{end -}}
+{{if not .Scheduled}}
 [Install]
-WantedBy=multi-user.target
+WantedBy={{if .UserUnit}}default.target{{else}}multi-user.target{{end}}
+{{end -}}
 `
 
-func runSystemdCommand(action string, args ...string) error {
-	cmd := exec.Command(action, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	return cmd.Run()
-}
-
 func IsSystemd() bool {
 	_, err := exec.LookPath("systemctl")
 	return err == nil
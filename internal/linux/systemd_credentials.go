@@ -0,0 +1,60 @@
+package linux
+
+import (
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"strings"
+)
+
+// credentialDirectives renders creds as [Service] lines for the given
+// systemd version, -1 when unknown. Unlike hardening settings, credentials
+// a version lacks are an error: the service could not start without them.
+func credentialDirectives(creds []service.Credential, version int64) ([]string, error) {
+	var lines []string
+	for _, c := range creds {
+		if err := c.Validate(); err != nil {
+			return nil, err
+		}
+
+		key, since := "LoadCredential", int64(247)
+		value := c.Path
+		if c.Value != "" {
+			key, value = "SetCredential", systemdCredentialValue(c.Value)
+		} else {
+			path, err := systemdPath(c.Path, false)
+			if err != nil {
+				return nil, fmt.Errorf("credential %s: %v", c.Name, err)
+			}
+			value = path
+		}
+		if c.Encrypted {
+			key, since = key+"Encrypted", 250
+		}
+		if version != -1 && version < since {
+			return nil, fmt.Errorf("credential %s: systemd %d does not support %s= (needs %d)", c.Name, version, key, since)
+		}
+		lines = append(lines, key+"="+systemdSpecifierEscape(c.Name, false)+":"+value)
+	}
+	return lines, nil
+}
+
+// systemdCredentialValue escapes a SetCredential= value, which systemd
+// unescapes C-style after expanding specifiers.
+func systemdCredentialValue(v string) string {
+	v = systemdSpecifierEscape(v, false)
+	var b strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c < 0x20 || c == 0x7f, c == ' ' && (i == 0 || i == len(v)-1):
+			// Whitespace at either end would be trimmed by the unit parser.
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
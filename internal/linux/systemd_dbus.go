@@ -0,0 +1,263 @@
+package linux
+
+import (
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"github.com/godbus/dbus/v5"
+	"sync"
+	"time"
+)
+
+const (
+	systemdBusName      = "org.freedesktop.systemd1"
+	systemdObjectPath   = dbus.ObjectPath("/org/freedesktop/systemd1")
+	systemdManagerIface = "org.freedesktop.systemd1.Manager"
+	systemdUnitIface    = "org.freedesktop.systemd1.Unit"
+
+	// systemdJobTimeout bounds how long we wait for a queued job to finish.
+	// systemd enforces its own start/stop timeouts well below this.
+	systemdJobTimeout = 10 * time.Minute
+)
+
+var errDBusUnsupported = errors.New("action not supported over D-Bus")
+
+// systemdDBus drives systemd through the org.freedesktop.systemd1 D-Bus API
+// instead of systemctl. Unit jobs block until systemd reports them finished.
+type systemdDBus struct {
+	connect func() (*dbus.Conn, error)
+	timeout time.Duration
+
+	mu   sync.Mutex
+	conn *dbus.Conn
+
+	jobsMu sync.Mutex
+	jobs   map[dbus.ObjectPath]chan string
+}
+
+// newSystemdDBus connects to the system bus, or for user services to the
+// session bus at sessionBus, the one in the environment when empty.
+func newSystemdDBus(user bool, sessionBus string) *systemdDBus {
+	connect := func() (*dbus.Conn, error) { return dbus.ConnectSystemBus() }
+	if user {
+		connect = func() (*dbus.Conn, error) { return dbus.ConnectSessionBus() }
+		if sessionBus != "" {
+			connect = func() (*dbus.Conn, error) { return dbus.Connect(sessionBus) }
+		}
+	}
+	return &systemdDBus{
+		connect: connect,
+		timeout: systemdJobTimeout,
+		jobs:    make(map[dbus.ObjectPath]chan string),
+	}
+}
+
+func (d *systemdDBus) connection() (*dbus.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.conn != nil && d.conn.Connected() {
+		return d.conn, nil
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	err = conn.AddMatchSignal(
+		dbus.WithMatchInterface(systemdManagerIface),
+		dbus.WithMatchMember("JobRemoved"),
+	)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	// Without a subscriber systemd may not emit job signals at all.
+	err = conn.Object(systemdBusName, systemdObjectPath).Call(systemdManagerIface+".Subscribe", 0).Err
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go d.dispatch(signals)
+
+	d.conn = conn
+	return conn, nil
+}
+
+// dispatch hands JobRemoved results to whoever queued the job.
+func (d *systemdDBus) dispatch(signals <-chan *dbus.Signal) {
+	for sig := range signals {
+		if sig.Name != systemdManagerIface+".JobRemoved" || len(sig.Body) < 4 {
+			continue
+		}
+		job, _ := sig.Body[1].(dbus.ObjectPath)
+		result, _ := sig.Body[3].(string)
+
+		d.jobsMu.Lock()
+		if done, found := d.jobs[job]; found {
+			done <- result
+			delete(d.jobs, job)
+		}
+		d.jobsMu.Unlock()
+	}
+}
+
+func (d *systemdDBus) manager() (dbus.BusObject, error) {
+	conn, err := d.connection()
+	if err != nil {
+		return nil, err
+	}
+	return conn.Object(systemdBusName, systemdObjectPath), nil
+}
+
+// run maps systemctl style actions onto the manager API. It returns
+// errDBusUnsupported for actions it does not know so the caller can fall
+// back to systemctl.
+func (d *systemdDBus) run(action string, args ...string) error {
+	switch action {
+	case "daemon-reload":
+		return d.reload()
+	case "enable":
+		return d.enable(args...)
+	case "disable":
+		return d.disable(args...)
+	}
+
+	method, found := map[string]string{
+		"start":             "StartUnit",
+		"stop":              "StopUnit",
+		"restart":           "RestartUnit",
+		"reload-or-restart": "ReloadOrRestartUnit",
+	}[action]
+	if !found {
+		return errDBusUnsupported
+	}
+	for _, unit := range args {
+		if err := d.runJob(action, method, unit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runJob queues a unit job and waits for its JobRemoved signal.
+func (d *systemdDBus) runJob(action, method, unit string) error {
+	obj, err := d.manager()
+	if err != nil {
+		return err
+	}
+
+	// Hold the lock across the call so the dispatcher cannot see the
+	// JobRemoved signal before the job is registered.
+	d.jobsMu.Lock()
+	var job dbus.ObjectPath
+	err = obj.Call(systemdManagerIface+"."+method, 0, unit, "replace").Store(&job)
+	if err != nil {
+		d.jobsMu.Unlock()
+		return err
+	}
+	done := make(chan string, 1)
+	d.jobs[job] = done
+	d.jobsMu.Unlock()
+
+	select {
+	case result := <-done:
+		if result != "done" {
+			return &service.JobError{Unit: unit, Operation: action, Result: result}
+		}
+		return nil
+	case <-time.After(d.timeout):
+		d.jobsMu.Lock()
+		delete(d.jobs, job)
+		d.jobsMu.Unlock()
+		return fmt.Errorf("%s %s: timed out waiting for job %s", action, unit, job)
+	}
+}
+
+type unitFileChange struct {
+	Type        string
+	Filename    string
+	Destination string
+}
+
+func (d *systemdDBus) enable(units ...string) error {
+	obj, err := d.manager()
+	if err != nil {
+		return err
+	}
+	var carriesInstallInfo bool
+	var changes []unitFileChange
+	return obj.Call(systemdManagerIface+".EnableUnitFiles", 0, units, false, true).Store(&carriesInstallInfo, &changes)
+}
+
+func (d *systemdDBus) disable(units ...string) error {
+	obj, err := d.manager()
+	if err != nil {
+		return err
+	}
+	var changes []unitFileChange
+	return obj.Call(systemdManagerIface+".DisableUnitFiles", 0, units, false).Store(&changes)
+}
+
+func (d *systemdDBus) reload() error {
+	obj, err := d.manager()
+	if err != nil {
+		return err
+	}
+	return obj.Call(systemdManagerIface+".Reload", 0).Err
+}
+
+// unitProperty reads a property of the org.freedesktop.systemd1.Unit
+// interface, loading the unit first if systemd does not hold it in memory.
+func (d *systemdDBus) unitProperty(unit, name string) (string, error) {
+	obj, err := d.manager()
+	if err != nil {
+		return "", err
+	}
+	var path dbus.ObjectPath
+	err = obj.Call(systemdManagerIface+".LoadUnit", 0, unit).Store(&path)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := d.connection()
+	if err != nil {
+		return "", err
+	}
+	v, err := conn.Object(systemdBusName, path).GetProperty(systemdUnitIface + "." + name)
+	if err != nil {
+		return "", err
+	}
+	value, ok := v.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected type %s for %s", v.Signature(), name)
+	}
+	return value, nil
+}
+
+func (d *systemdDBus) status(unit string) (service.Status, error) {
+	loadState, err := d.unitProperty(unit, "LoadState")
+	if err != nil {
+		return service.StatusUnknown, err
+	}
+	if loadState == "not-found" {
+		return service.StatusUnknown, service.ErrNotInstalled
+	}
+
+	activeState, err := d.unitProperty(unit, "ActiveState")
+	if err != nil {
+		return service.StatusUnknown, err
+	}
+	switch activeState {
+	case "active", "activating", "reloading":
+		return service.StatusRunning, nil
+	case "inactive", "deactivating":
+		return service.StatusStopped, nil
+	case "failed":
+		return service.StatusUnknown, errors.New("service in failed state")
+	default:
+		return service.StatusUnknown, service.ErrNotInstalled
+	}
+}
@@ -0,0 +1,189 @@
+package linux
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"github.com/godbus/dbus/v5"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testBusConfig = `<!DOCTYPE busconfig PUBLIC "-//freedesktop//DTD D-Bus Bus Configuration 1.0//EN"
+ "http://www.freedesktop.org/standards/dbus/1.0/busconfig.dtd">
+<busconfig>
+  <type>session</type>
+  <listen>unix:path=%s</listen>
+  <policy context="default">
+    <allow send_destination="*"/>
+    <allow receive_sender="*"/>
+    <allow own="*"/>
+  </policy>
+</busconfig>
+`
+
+// startTestBus runs a private dbus-daemon and returns its address.
+func startTestBus(t *testing.T) string {
+	daemon, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not available")
+	}
+
+	dir := t.TempDir()
+	conf := filepath.Join(dir, "bus.conf")
+	err = os.WriteFile(conf, []byte(fmt.Sprintf(testBusConfig, filepath.Join(dir, "bus"))), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(daemon, "--config-file="+conf, "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("Failed to read bus address: %v", err)
+	}
+	return strings.TrimSpace(addr)
+}
+
+// fakeSystemd implements just enough of org.freedesktop.systemd1.Manager.
+type fakeSystemd struct {
+	conn    *dbus.Conn
+	results map[string]string // Job result per unit, "done" when absent.
+
+	mu    sync.Mutex
+	calls []string
+	jobs  uint32
+}
+
+func (f *fakeSystemd) record(call string) {
+	f.mu.Lock()
+	f.calls = append(f.calls, call)
+	f.mu.Unlock()
+}
+
+func (f *fakeSystemd) queue(method, unit string) (dbus.ObjectPath, *dbus.Error) {
+	f.record(method + " " + unit)
+	f.mu.Lock()
+	f.jobs++
+	id := f.jobs
+	f.mu.Unlock()
+
+	result, found := f.results[unit]
+	if !found {
+		result = "done"
+	}
+	job := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/systemd1/job/%d", id))
+	// Emitted concurrently with the reply, as systemd may do.
+	go f.conn.Emit(systemdObjectPath, systemdManagerIface+".JobRemoved", id, job, unit, result)
+	return job, nil
+}
+
+func (f *fakeSystemd) Subscribe() *dbus.Error { return nil }
+func (f *fakeSystemd) Reload() *dbus.Error {
+	f.record("Reload")
+	return nil
+}
+func (f *fakeSystemd) StartUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return f.queue("StartUnit", name)
+}
+func (f *fakeSystemd) StopUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return f.queue("StopUnit", name)
+}
+func (f *fakeSystemd) RestartUnit(name, mode string) (dbus.ObjectPath, *dbus.Error) {
+	return f.queue("RestartUnit", name)
+}
+func (f *fakeSystemd) EnableUnitFiles(files []string, runtime, force bool) (bool, []unitFileChange, *dbus.Error) {
+	f.record("EnableUnitFiles " + strings.Join(files, " "))
+	return true, nil, nil
+}
+
+func newFakeSystemd(t *testing.T, addr string, results map[string]string) *fakeSystemd {
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	f := &fakeSystemd{conn: conn, results: results}
+	if err := conn.Export(f, systemdObjectPath, systemdManagerIface); err != nil {
+		t.Fatal(err)
+	}
+	reply, err := conn.RequestName(systemdBusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		t.Fatalf("Failed to own %s: %v", systemdBusName, err)
+	}
+	return f
+}
+
+func newDBusTestService(t *testing.T, addr string) *systemdService {
+	d := newSystemdDBus(false, "")
+	d.connect = func() (*dbus.Conn, error) { return dbus.Connect(addr) }
+	t.Cleanup(func() {
+		if d.conn != nil {
+			d.conn.Close()
+		}
+	})
+	return &systemdService{
+		Name:   "worker",
+		Config: &service.Config{Name: "worker"},
+		dbus:   d,
+	}
+}
+
+func TestSystemdDBusJobs(t *testing.T) {
+	addr := startTestBus(t)
+	fake := newFakeSystemd(t, addr, map[string]string{})
+	s := newDBusTestService(t, addr)
+
+	for i := 0; i < 20; i++ {
+		if err := s.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+	}
+	if err := s.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := s.runAction("enable"); err != nil {
+		t.Fatalf("Enable failed: %v", err)
+	}
+	if err := s.run("daemon-reload"); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	want := []string{"StopUnit worker.service", "EnableUnitFiles worker.service", "Reload"}
+	if got := fake.calls[len(fake.calls)-3:]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected calls %v, got %v", want, got)
+	}
+}
+
+func TestSystemdDBusJobResult(t *testing.T) {
+	addr := startTestBus(t)
+	newFakeSystemd(t, addr, map[string]string{"worker.service": "failed"})
+	s := newDBusTestService(t, addr)
+
+	var jobErr *service.JobError
+	if err := s.Start(); !errors.As(err, &jobErr) {
+		t.Fatalf("Expected a job error, got %v", err)
+	}
+	if jobErr.Result != "failed" || jobErr.Operation != "start" {
+		t.Errorf("Unexpected job error: %+v", jobErr)
+	}
+}
@@ -0,0 +1,92 @@
+package linux
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// dropInDir is where drop-ins amending the service unit live.
+func (s *systemdService) dropInDir() (string, error) {
+	path, err := s.ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return path + ".d", nil
+}
+
+func (s *systemdService) dropInPath(name string) (string, error) {
+	if name == "" || strings.ContainsRune(name, '/') || name == ".conf" {
+		return "", fmt.Errorf("invalid drop-in name %q", name)
+	}
+	if !strings.HasSuffix(name, ".conf") {
+		name += ".conf"
+	}
+	dir, err := s.dropInDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// SetDropIn writes the drop-in name, e.g. "memory" or "50-env.conf", and
+// reloads systemd so it applies on the next (re)start. content is unit file
+// syntax, section headers included.
+func (s *systemdService) SetDropIn(name string, content []byte) error {
+	path, err := s.dropInPath(name)
+	if err != nil {
+		return err
+	}
+	if err := s.writeUnitFile(path, content); err != nil {
+		return err
+	}
+	return s.run("daemon-reload")
+}
+
+// DropIns lists the drop-in file names in the order systemd applies them.
+func (s *systemdService) DropIns() ([]string, error) {
+	dir, err := s.dropInDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".conf") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *systemdService) DropIn(name string) ([]byte, error) {
+	path, err := s.dropInPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
+
+// RemoveDropIn deletes the drop-in and reloads systemd. The drop-in
+// directory goes away with its last file.
+func (s *systemdService) RemoveDropIn(name string) error {
+	path, err := s.dropInPath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return err
+	}
+	_ = os.Remove(filepath.Dir(path)) // Fails, harmlessly, unless empty.
+	return s.run("daemon-reload")
+}
@@ -0,0 +1,74 @@
+package linux
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// EnvironmentFile returns the file the unit loads its environment from:
+// /etc/sysconfig/<name> or /etc/default/<name>, or <name>.env next to the
+// units of a user service.
+func (s *systemdService) EnvironmentFile() (string, error) {
+	if !s.IsUserService() {
+		return filepath.Join(envFileDir(), s.Config.Name), nil
+	}
+	return s.unitPath(s.Config.Name + ".env")
+}
+
+// hasEnvironment reports whether the Config asks for an environment file.
+// Only SecretEnvVars go there; EnvVars stay Environment= lines of the unit,
+// where template instances can use %i.
+func (s *systemdService) hasEnvironment() bool {
+	return len(s.Config.SecretEnvVars) > 0
+}
+
+// environment renders the content of the environment file.
+func (s *systemdService) environment() ([]byte, error) {
+	return renderEnvFile(nil, s.Config.SecretEnvVars)
+}
+
+// WriteEnvironment implements service.EnvironmentManager.
+func (s *systemdService) WriteEnvironment() error {
+	path, err := s.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	content, err := s.environment()
+	if err != nil {
+		return err
+	}
+	return s.writeEnvironmentFile(path, content)
+}
+
+// RemoveEnvironment implements service.EnvironmentManager.
+func (s *systemdService) RemoveEnvironment() error {
+	path, err := s.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// writeEnvironmentFile writes the environment file owned by root, or by
+// the user running a user service.
+func (s *systemdService) writeEnvironmentFile(path string, content []byte) error {
+	uid, gid := 0, 0
+	if s.IsUserService() {
+		u, err := lookupSystemdUser()
+		if err != nil {
+			return err
+		}
+		uid, gid = u.Uid, u.Gid
+	}
+	return writeEnvFile(path, content, uid, gid)
+}
+
+// maskedEnvironment renders the environment file with the secrets blanked
+// out, for previews.
+func (s *systemdService) maskedEnvironment() ([]byte, error) {
+	return renderMaskedEnvFile(nil, s.Config.SecretEnvVars)
+}
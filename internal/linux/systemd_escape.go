@@ -0,0 +1,116 @@
+package linux
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// systemdFuncs returns the template functions escaping values for unit
+// files, see systemd.syntax(7) and systemd.service(7). With keepInstance
+// set the %i and %I specifiers survive escaping, so template units can use
+// them in Arguments, EnvVars and WorkingDirectory.
+func systemdFuncs(keepInstance bool) template.FuncMap {
+	funcs := template.FuncMap{
+		"execArg": func(s string) string { return systemdExecArg(s, keepInstance) },
+		"path":    func(s string) (string, error) { return systemdPath(s, keepInstance) },
+		"env":     func(k, v string) (string, error) { return systemdEnv(k, v, keepInstance) },
+		"specifiers": func(s string) string {
+			return systemdSpecifierEscape(strings.ReplaceAll(s, "\n", " "), keepInstance)
+		},
+	}
+	for name, f := range TF {
+		funcs[name] = f
+	}
+	return funcs
+}
+
+// systemdSpecifierEscape doubles every % so systemd does not expand it.
+func systemdSpecifierEscape(s string, keepInstance bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' {
+			if keepInstance && i+1 < len(s) && (s[i+1] == 'i' || s[i+1] == 'I') {
+				b.WriteString(s[i : i+2])
+				i++
+				continue
+			}
+			b.WriteByte('%')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// systemdQuoteWord returns s as a single word of a space separated list,
+// double quoting and C-escaping it when needed.
+func systemdQuoteWord(s string) string {
+	if s == ";" {
+		// A lone semicolon separates commands.
+		return `\;`
+	}
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\") && !hasControl(s) {
+		return s
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if c < 0x20 || c == 0x7f {
+				fmt.Fprintf(&b, `\x%02x`, c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// systemdExecArg escapes a word of ExecStart=. Besides quoting, % and $
+// are doubled so neither specifiers nor environment variables expand.
+func systemdExecArg(s string, keepInstance bool) string {
+	s = systemdSpecifierEscape(s, keepInstance)
+	return systemdQuoteWord(strings.ReplaceAll(s, "$", "$$"))
+}
+
+// systemdPath escapes the value of a path setting such as WorkingDirectory=.
+// Path settings take the rest of the line verbatim, so only specifiers need
+// escaping; what cannot be expressed on one line is an error.
+func systemdPath(s string, keepInstance bool) (string, error) {
+	if hasControl(s) || strings.TrimSpace(s) != s {
+		return "", fmt.Errorf("path %q cannot be used in a unit file", s)
+	}
+	return systemdSpecifierEscape(s, keepInstance), nil
+}
+
+// systemdEnv renders the value of an Environment= line assigning v to k.
+func systemdEnv(k, v string, keepInstance bool) (string, error) {
+	if !envNamePattern.MatchString(k) {
+		return "", fmt.Errorf("invalid environment variable name %q", k)
+	}
+	return systemdQuoteWord(systemdSpecifierEscape(k+"="+v, keepInstance)), nil
+}
+
+func hasControl(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 0x20 || s[i] == 0x7f {
+			return true
+		}
+	}
+	return false
+}
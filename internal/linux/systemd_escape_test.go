@@ -0,0 +1,126 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"strings"
+	"testing"
+)
+
+func TestSystemdExecArg(t *testing.T) {
+	tests := []struct {
+		in, want     string
+		keepInstance bool
+	}{
+		{"plain", "plain", false},
+		{"", `""`, false},
+		{"two words", `"two words"`, false},
+		{`say "hi"`, `"say \"hi\""`, false},
+		{"it's", `"it's"`, false},
+		{`C:\dir`, `"C:\\dir"`, false},
+		{"line\nbreak", `"line\nbreak"`, false},
+		{"tab\there", `"tab\there"`, false},
+		{"bell\a", `"bell\x07"`, false},
+		{"100%", "100%%", false},
+		{"%i", "%%i", false},
+		{"%i", "%i", true},
+		{"%h/%I", "%%h/%I", true},
+		{"$HOME", "$$HOME", false},
+		{"${HOME} dir", `"$${HOME} dir"`, false},
+		{";", `\;`, false},
+		{"a;b", "a;b", false},
+	}
+	for _, tt := range tests {
+		if got := systemdExecArg(tt.in, tt.keepInstance); got != tt.want {
+			t.Errorf("systemdExecArg(%q, %v) = %s, want %s", tt.in, tt.keepInstance, got, tt.want)
+		}
+	}
+}
+
+func TestSystemdPath(t *testing.T) {
+	tests := []struct {
+		in, want string
+		fails    bool
+	}{
+		{"/srv/app", "/srv/app", false},
+		{"/srv/my app", "/srv/my app", false},
+		{`/srv/"quoted"`, `/srv/"quoted"`, false},
+		{"/srv/100%", "/srv/100%%", false},
+		{"/srv/a\nb", "", true},
+		{" /srv/app", "", true},
+		{"/srv/app ", "", true},
+	}
+	for _, tt := range tests {
+		got, err := systemdPath(tt.in, false)
+		if (err != nil) != tt.fails {
+			t.Errorf("systemdPath(%q) error = %v, want failure %v", tt.in, err, tt.fails)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("systemdPath(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSystemdEnv(t *testing.T) {
+	tests := []struct {
+		k, v, want string
+		fails      bool
+	}{
+		{"PORT", "8080", "PORT=8080", false},
+		{"EMPTY", "", "EMPTY=", false},
+		{"GREETING", "hello world", `"GREETING=hello world"`, false},
+		{"JSON", `{"a":1}`, `"JSON={\"a\":1}"`, false},
+		{"MULTI", "a\nb", `"MULTI=a\nb"`, false},
+		{"RATE", "50%", "RATE=50%%", false},
+		{"PATHS", `C:\x`, `"PATHS=C:\\x"`, false},
+		{"_OK1", "$x", "_OK1=$x", false},
+		{"1BAD", "x", "", true},
+		{"BAD NAME", "x", "", true},
+		{"BAD=NAME", "x", "", true},
+		{"", "x", "", true},
+	}
+	for _, tt := range tests {
+		got, err := systemdEnv(tt.k, tt.v, false)
+		if (err != nil) != tt.fails {
+			t.Errorf("systemdEnv(%q, %q) error = %v, want failure %v", tt.k, tt.v, err, tt.fails)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("systemdEnv(%q, %q) = %s, want %s", tt.k, tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSystemdUnitEscaping(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:             "escape",
+		Description:      "Uses 100% CPU",
+		Arguments:        []string{"--name", "my app", `--msg="hi"`, "$HOME"},
+		WorkingDirectory: "/srv/my app",
+		EnvVars:          map[string]string{"GREETING": "hello world"},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"Description=Uses 100%% CPU\n",
+		`ExecStart=/usr/bin/escape --name "my app" "--msg=\"hi\"" $$HOME` + "\n",
+		"WorkingDirectory=/srv/my app\n",
+		`Environment="GREETING=hello world"` + "\n",
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("Expected %q in unit:\n%s", want, unit)
+		}
+	}
+
+	s.Config.EnvVars = map[string]string{"NOT VALID": "x"}
+	if _, err := s.renderUnit(); err == nil {
+		t.Error("Expected an invalid environment variable name to fail rendering")
+	}
+	s.Config.EnvVars = nil
+	s.Config.WorkingDirectory = "/srv/a\nb"
+	if _, err := s.renderUnit(); err == nil {
+		t.Error("Expected a path with a newline to fail rendering")
+	}
+}
@@ -0,0 +1,134 @@
+package linux
+
+import (
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"strings"
+)
+
+// hardening returns the sandboxing settings of the service: Config.Hardening
+// when set, the preset named by OptionHardening otherwise.
+func (s *systemdService) hardening() (*service.Hardening, error) {
+	if s.Config.Hardening != nil {
+		return s.Config.Hardening, nil
+	}
+	return service.HardeningPreset(s.Config.Option.String(service.OptionHardening, service.HardeningNone))
+}
+
+// hardeningDirectives renders h as unit file lines for the given systemd
+// version, -1 when unknown. Settings the version does not understand are
+// left out and reported in warnings.
+func hardeningDirectives(h *service.Hardening, version int64) (lines, warnings []string) {
+	add := func(key, value string, since int64) {
+		if version != -1 && version < since {
+			warnings = append(warnings, fmt.Sprintf("systemd %d does not support %s=%s (needs %d), leaving it out", version, key, value, since))
+			return
+		}
+		lines = append(lines, key+"="+value)
+	}
+
+	if h.NoNewPrivileges {
+		add("NoNewPrivileges", "true", 187)
+	}
+	switch h.ProtectSystem {
+	case "":
+	case "strict":
+		add("ProtectSystem", h.ProtectSystem, 232)
+	default:
+		add("ProtectSystem", h.ProtectSystem, 214)
+	}
+	switch h.ProtectHome {
+	case "":
+	case "tmpfs":
+		add("ProtectHome", h.ProtectHome, 242)
+	default:
+		add("ProtectHome", h.ProtectHome, 214)
+	}
+	if h.PrivateTmp {
+		add("PrivateTmp", "true", 183)
+	}
+	if h.PrivateDevices {
+		add("PrivateDevices", "true", 209)
+	}
+	if len(h.ReadWritePaths) > 0 {
+		paths := make([]string, len(h.ReadWritePaths))
+		for i, p := range h.ReadWritePaths {
+			paths[i] = systemdQuoteWord(systemdSpecifierEscape(p, false))
+		}
+		// Older releases know the same setting by its former name.
+		if version != -1 && version < 231 {
+			add("ReadWriteDirectories", strings.Join(paths, " "), 0)
+		} else {
+			add("ReadWritePaths", strings.Join(paths, " "), 231)
+		}
+	}
+	if h.CapabilityBoundingSet != nil {
+		add("CapabilityBoundingSet", strings.Join(h.CapabilityBoundingSet, " "), 0)
+	}
+	if len(h.AmbientCapabilities) > 0 {
+		add("AmbientCapabilities", strings.Join(h.AmbientCapabilities, " "), 229)
+	}
+	if len(h.RestrictAddressFamilies) > 0 {
+		add("RestrictAddressFamilies", strings.Join(h.RestrictAddressFamilies, " "), 211)
+	}
+	if len(h.SystemCallFilter) > 0 {
+		since := int64(187)
+		var unknown []string
+		for _, f := range h.SystemCallFilter {
+			group := strings.TrimPrefix(f, "~")
+			if !strings.HasPrefix(group, "@") {
+				continue
+			}
+			groupSince, ok := systemCallGroupSince[group]
+			if !ok {
+				unknown = append(unknown, group)
+			}
+			if groupSince > since {
+				since = groupSince
+			}
+		}
+		filter := strings.Join(h.SystemCallFilter, " ")
+		if len(unknown) > 0 {
+			warnings = append(warnings, fmt.Sprintf("unknown syscall group %s in SystemCallFilter=%s, leaving it out", strings.Join(unknown, ", "), filter))
+		} else {
+			add("SystemCallFilter", filter, since)
+		}
+	}
+	return lines, warnings
+}
+
+// systemCallGroupSince lists the syscall groups with the systemd version
+// that added them. Filters naming a group missing from it are left out:
+// systemd skips groups it does not know, cutting an allow list short.
+var systemCallGroupSince = map[string]int64{
+	"@clock":          231,
+	"@cpu-emulation":  231,
+	"@debug":          231,
+	"@io-event":       231,
+	"@ipc":            231,
+	"@keyring":        231,
+	"@module":         231,
+	"@mount":          231,
+	"@network-io":     231,
+	"@obsolete":       231,
+	"@privileged":     231,
+	"@process":        231,
+	"@raw-io":         231,
+	"@basic-io":       233,
+	"@default":        233,
+	"@file-system":    233,
+	"@reboot":         233,
+	"@resources":      233,
+	"@swap":           233,
+	"@aio":            235,
+	"@chown":          235,
+	"@memlock":        235,
+	"@setuid":         235,
+	"@signal":         235,
+	"@sync":           235,
+	"@timer":          235,
+	"@system-service": 239,
+	"@pkey":           244,
+	"@known":          247,
+	"@sandbox":        253,
+}
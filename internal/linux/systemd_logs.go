@@ -0,0 +1,136 @@
+package linux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Logs implements service.LogReader. Services writing their output to
+// files through OptionLogOutput are read from LogDirectory, all others from
+// the journal.
+func (s *systemdService) Logs(ctx context.Context, opts service.LogOptions) (service.LogStream, error) {
+	if s.IsTemplate() && s.instance == "" {
+		return nil, errNoInstance
+	}
+	if s.Config.Option.Bool(service.OptionLogOutput, service.OptionLogOutputDefault) && s.HasOutputFileSupport() {
+		dir := s.Config.Option.String(service.OptionLogDirectory, service.OptionLogDirectoryDefault)
+		return fileLogs(ctx, []logFile{
+			{filepath.Join(dir, s.Name+".out"), service.PriorityInfo},
+			{filepath.Join(dir, s.Name+".err"), service.PriorityErr},
+		}, opts), nil
+	}
+
+	args := s.journalctlArgs(opts)
+	stream, ok := s.runner.(runners.StreamRunner)
+	if !ok {
+		if opts.Follow {
+			return nil, fmt.Errorf("following logs needs a streaming runner: %w", service.ErrNotSupported)
+		}
+		_, out, err := s.RunWithOutput("journalctl", args...)
+		if err != nil {
+			return nil, err
+		}
+		return newLogStream(ctx, func(ctx context.Context, emit func(service.LogEntry) bool) error {
+			return emitJournalJSON(strings.NewReader(out), emit)
+		}), nil
+	}
+
+	r, err := stream.Stream(ctx, "journalctl", args...)
+	if err != nil {
+		return nil, err
+	}
+	return newLogStream(ctx, func(ctx context.Context, emit func(service.LogEntry) bool) error {
+		// Closing the stream is what unblocks a read waiting on --follow.
+		stop := context.AfterFunc(ctx, func() { r.Close() })
+		defer stop()
+		defer r.Close()
+		return emitJournalJSON(r, emit)
+	}), nil
+}
+
+func (s *systemdService) journalctlArgs(opts service.LogOptions) []string {
+	args := []string{"-o", "json", "--no-pager"}
+	if s.IsUserService() {
+		args = append(args, "--user-unit="+s.UnitName())
+	} else {
+		args = append(args, "--unit="+s.UnitName())
+	}
+	if !opts.Since.IsZero() {
+		args = append(args, "--since=@"+strconv.FormatInt(opts.Since.Unix(), 10))
+	}
+	if !opts.Until.IsZero() {
+		args = append(args, "--until=@"+strconv.FormatInt(opts.Until.Unix(), 10))
+	}
+	switch {
+	case opts.Lines > 0:
+		args = append(args, "--lines="+strconv.Itoa(opts.Lines))
+	case opts.Follow:
+		// --follow alone starts with the last ten entries.
+		args = append(args, "--lines=all")
+	}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	return args
+}
+
+// journalJSON is the part of a `journalctl -o json` record Logs uses.
+type journalJSON struct {
+	Realtime string          `json:"__REALTIME_TIMESTAMP"`
+	Priority string          `json:"PRIORITY"`
+	Message  json.RawMessage `json:"MESSAGE"`
+}
+
+func emitJournalJSON(r io.Reader, emit func(service.LogEntry) bool) error {
+	scan := bufio.NewScanner(r)
+	scan.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scan.Scan() {
+		if len(scan.Bytes()) == 0 {
+			continue
+		}
+		e, err := parseJournalJSON(scan.Bytes())
+		if err != nil {
+			return err
+		}
+		if !emit(e) {
+			return nil
+		}
+	}
+	return scan.Err()
+}
+
+func parseJournalJSON(line []byte) (service.LogEntry, error) {
+	var j journalJSON
+	if err := json.Unmarshal(line, &j); err != nil {
+		return service.LogEntry{}, fmt.Errorf("journal entry: %v", err)
+	}
+
+	e := service.LogEntry{Priority: service.PriorityInfo}
+	if usec, err := strconv.ParseInt(j.Realtime, 10, 64); err == nil {
+		e.Time = time.UnixMicro(usec)
+	}
+	if p, err := strconv.Atoi(j.Priority); err == nil {
+		e.Priority = p
+	}
+	// Messages that are not valid UTF-8 come as arrays of bytes.
+	if err := json.Unmarshal(j.Message, &e.Message); err != nil {
+		var raw []byte
+		var ints []int
+		if json.Unmarshal(j.Message, &ints) == nil {
+			for _, b := range ints {
+				raw = append(raw, byte(b))
+			}
+		}
+		e.Message = string(raw)
+	}
+	return e, nil
+}
@@ -0,0 +1,44 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+)
+
+// Render returns the unit files and systemctl commands a fresh Install
+// would produce. Secret environment values are masked.
+func (s *systemdService) Render() (*service.Rendering, error) {
+	units, err := s.renderUnits()
+	if err != nil {
+		return nil, err
+	}
+
+	r := &service.Rendering{}
+	for _, u := range units {
+		r.Files = append(r.Files, service.RenderedFile{Path: u.Path, Mode: 0644, Content: u.Content})
+	}
+	if s.hasEnvironment() {
+		path, err := s.EnvironmentFile()
+		if err != nil {
+			return nil, err
+		}
+		content, err := s.maskedEnvironment()
+		if err != nil {
+			return nil, err
+		}
+		r.Files = append(r.Files, service.RenderedFile{Path: path, Mode: 0600, Content: content})
+	}
+	if s.lingers() {
+		cmd, err := s.lingerCommand()
+		if err != nil {
+			return nil, err
+		}
+		r.Commands = append(r.Commands, cmd)
+	}
+	for _, u := range units {
+		if u.Enable {
+			r.Commands = append(r.Commands, append([]string{"systemctl"}, s.systemctlArgs("enable", u.Name)...))
+		}
+	}
+	r.Commands = append(r.Commands, append([]string{"systemctl"}, s.systemctlArgs("daemon-reload")...))
+	return r, nil
+}
@@ -0,0 +1,92 @@
+package linux
+
+import (
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"strconv"
+	"time"
+)
+
+// systemdResourceProperties lists the properties resourcesFromProperties reads.
+func systemdResourceProperties() []string {
+	names := []string{"MemoryMax", "MemoryHigh", "CPUQuotaPerSecUSec", "CPUWeight", "TasksMax", "IOWeight", "Nice", "OOMScoreAdjust"}
+	for _, name := range service.RlimitNames {
+		names = append(names, "Limit"+name, "Limit"+name+"Soft")
+	}
+	return names
+}
+
+// resourceDirectives renders r as unit file lines after validating it.
+func resourceDirectives(r *service.Resources) ([]string, error) {
+	if r == nil {
+		return nil, nil
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	add := func(key, value string) {
+		if value != "" {
+			lines = append(lines, key+"="+value)
+		}
+	}
+	itoa := func(v int) string {
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	}
+
+	add("MemoryMax", r.MemoryMax)
+	add("MemoryHigh", r.MemoryHigh)
+	add("CPUQuota", r.CPUQuota)
+	add("CPUWeight", itoa(r.CPUWeight))
+	add("TasksMax", r.TasksMax)
+	add("IOWeight", itoa(r.IOWeight))
+	if r.Nice != nil {
+		add("Nice", strconv.Itoa(*r.Nice))
+	}
+	if r.OOMScoreAdjust != nil {
+		add("OOMScoreAdjust", strconv.Itoa(*r.OOMScoreAdjust))
+	}
+	for _, name := range service.RlimitNames {
+		add("Limit"+name, r.Limits[name])
+	}
+	return lines, nil
+}
+
+// resourcesFromProperties turns the effective settings reported by
+// `systemctl show` back into Resources.
+func resourcesFromProperties(props map[string]string) *service.Resources {
+	r := &service.Resources{
+		MemoryMax:  props["MemoryMax"],
+		MemoryHigh: props["MemoryHigh"],
+		CPUWeight:  int(parseSystemdUint(props["CPUWeight"])),
+		TasksMax:   props["TasksMax"],
+		IOWeight:   int(parseSystemdUint(props["IOWeight"])),
+		Limits:     make(map[string]string),
+	}
+	if quota, err := time.ParseDuration(props["CPUQuotaPerSecUSec"]); err == nil {
+		r.CPUQuota = fmt.Sprintf("%g%%", quota.Seconds()*100)
+	}
+	// systemctl reports 0 for both when unset, which cannot be told apart
+	// from an explicit 0, so that is left unset.
+	if nice, err := strconv.Atoi(props["Nice"]); err == nil && nice != 0 {
+		r.Nice = &nice
+	}
+	if adj, err := strconv.Atoi(props["OOMScoreAdjust"]); err == nil && adj != 0 {
+		r.OOMScoreAdjust = &adj
+	}
+	for _, name := range service.RlimitNames {
+		hard, found := props["Limit"+name]
+		if !found {
+			continue
+		}
+		if soft := props["Limit"+name+"Soft"]; soft != "" && soft != hard {
+			hard = soft + ":" + hard
+		}
+		r.Limits[name] = hard
+	}
+	return r
+}
@@ -0,0 +1,129 @@
+package linux
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"net"
+	"strings"
+	"text/template"
+)
+
+type systemdSocketData struct {
+	*service.Config
+	Unit    string
+	Listens []systemdListen
+	FDName  string
+}
+
+type systemdListen struct {
+	Directive string
+	Address   string
+}
+
+func (s *systemdService) SocketUnitName() string { return s.Config.Name + ".socket" }
+
+// HasSockets reports whether the service is socket activated.
+func (s *systemdService) HasSockets() bool { return len(s.Config.Listen) > 0 }
+
+func (s *systemdService) renderSocketUnit() ([]byte, error) {
+	data := &systemdSocketData{Config: s.Config, Unit: s.UnitName()}
+	for _, l := range s.Config.Listen {
+		listen, err := systemdListenDirective(l)
+		if err != nil {
+			return nil, err
+		}
+		data.Listens = append(data.Listens, listen)
+	}
+	fdName, err := systemdFDName(s.Config.Listen)
+	if err != nil {
+		return nil, err
+	}
+	data.FDName = fdName
+
+	var buf bytes.Buffer
+	err = template.Must(template.New("").Funcs(TF).Parse(systemdSocketScript)).Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// systemdFDName returns the FileDescriptorName= of the socket unit. It
+// names every descriptor of the unit, so it is only set when the named
+// addresses share one name; otherwise Listeners names them by position.
+func systemdFDName(listen []service.ListenAddress) (string, error) {
+	var name string
+	for _, l := range listen {
+		if l.Name == "" {
+			continue
+		}
+		if len(l.Name) > 255 || strings.Contains(l.Name, ":") || hasControl(l.Name) || strings.TrimSpace(l.Name) != l.Name {
+			return "", fmt.Errorf("listen address %q: invalid name %q", l.Address, l.Name)
+		}
+		if name != "" && name != l.Name {
+			return "", nil
+		}
+		name = l.Name
+	}
+	return systemdSpecifierEscape(name, false), nil
+}
+
+// systemdListenDirective maps a ListenAddress onto the matching Listen*=
+// line. Addresses without a host listen on every address, as net.Listen does.
+func systemdListenDirective(l service.ListenAddress) (systemdListen, error) {
+	unixDirective := ""
+	switch l.Network {
+	case "unix":
+		unixDirective = "ListenStream"
+	case "unixgram":
+		unixDirective = "ListenDatagram"
+	case "unixpacket":
+		unixDirective = "ListenSequentialPacket"
+	}
+	if unixDirective != "" {
+		path, err := systemdPath(l.Address, false)
+		if err != nil {
+			return systemdListen{}, err
+		}
+		return systemdListen{unixDirective, path}, nil
+	}
+
+	host, port, err := net.SplitHostPort(l.Address)
+	if err != nil {
+		return systemdListen{}, fmt.Errorf("listen address %q: %v", l.Address, err)
+	}
+	directive := "ListenStream"
+	switch l.Network {
+	case "tcp", "tcp4", "tcp6":
+	case "udp", "udp4", "udp6":
+		directive = "ListenDatagram"
+	default:
+		return systemdListen{}, fmt.Errorf("listen address %q: unsupported network %q", l.Address, l.Network)
+	}
+
+	switch {
+	case host != "":
+		return systemdListen{directive, net.JoinHostPort(host, port)}, nil
+	case l.Network == "tcp4" || l.Network == "udp4":
+		return systemdListen{directive, "0.0.0.0:" + port}, nil
+	case l.Network == "tcp6" || l.Network == "udp6":
+		return systemdListen{directive, "[::]:" + port}, nil
+	}
+	return systemdListen{directive, port}, nil
+}
+
+const systemdSocketScript = `[Unit]
+Description={{.Name}} sockets
+
+[Socket]
+{{range .Listens -}}
+{{.Directive}}={{.Address}}
+{{end -}}
+{{if .FDName}}FileDescriptorName={{.FDName}}
+{{end -}}
+Service={{.Unit}}
+
+[Install]
+WantedBy=sockets.target
+`
@@ -0,0 +1,121 @@
+package linux
+
+import (
+	"bufio"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemdTimestampLayout is how `systemctl show` prints timestamps.
+const systemdTimestampLayout = "Mon 2006-01-02 15:04:05 MST"
+
+var systemdDetailProperties = []string{
+	"ActiveState",
+	"SubState",
+	"LoadState",
+	"UnitFileState",
+	"MainPID",
+	"ExecMainStatus",
+	"NRestarts",
+	"ActiveEnterTimestamp",
+	"MemoryCurrent",
+	"CPUUsageNSec",
+}
+
+func (s *systemdService) StatusDetail() (service.StatusDetail, error) {
+	if s.IsTemplate() && s.instance == "" {
+		return service.StatusDetail{}, errNoInstance
+	}
+	names := append(append([]string{}, systemdDetailProperties...), systemdResourceProperties()...)
+	props, err := s.showProperties(s.UnitName(), names...)
+	if err != nil {
+		return service.StatusDetail{}, err
+	}
+
+	d := service.StatusDetail{
+		ActiveState:          props["ActiveState"],
+		SubState:             props["SubState"],
+		LoadState:            props["LoadState"],
+		UnitFileState:        props["UnitFileState"],
+		MainPID:              int(parseSystemdUint(props["MainPID"])),
+		ExecMainStatus:       int(parseSystemdUint(props["ExecMainStatus"])),
+		NRestarts:            int(parseSystemdUint(props["NRestarts"])),
+		ActiveEnterTimestamp: parseSystemdTimestamp(props["ActiveEnterTimestamp"]),
+		MemoryCurrent:        parseSystemdUint(props["MemoryCurrent"]),
+		CPUUsage:             time.Duration(parseSystemdUint(props["CPUUsageNSec"])),
+		Resources:            resourcesFromProperties(props),
+		Properties:           props,
+	}
+	if d.LoadState == "not-found" {
+		return d, service.ErrNotInstalled
+	}
+
+	switch d.ActiveState {
+	case "active", "activating", "reloading":
+		d.Status = service.StatusRunning
+	case "inactive", "failed", "deactivating":
+		d.Status = service.StatusStopped
+	default:
+		d.Status = service.StatusUnknown
+	}
+	if s.IsScheduled() {
+		return d, s.timerDetail(&d)
+	}
+	return d, nil
+}
+
+// showProperties runs `systemctl show` for unit and returns the requested
+// properties keyed by name.
+func (s *systemdService) showProperties(unit string, names ...string) (map[string]string, error) {
+	args := []string{"show"}
+	for _, name := range names {
+		args = append(args, "-p", name)
+	}
+	args = append(args, unit)
+
+	_, out, err := s.systemctl(args...)
+	if err != nil && !runners.IsExitError(err) {
+		return nil, err
+	}
+	if err != nil && out == "" {
+		return nil, err
+	}
+	return parseSystemdProperties(out), nil
+}
+
+func parseSystemdProperties(out string) map[string]string {
+	props := make(map[string]string)
+	scan := bufio.NewScanner(strings.NewReader(out))
+	for scan.Scan() {
+		key, value, found := strings.Cut(scan.Text(), "=")
+		if !found {
+			continue
+		}
+		props[key] = value
+	}
+	return props
+}
+
+// parseSystemdUint parses a numeric property, treating "[not set]", empty
+// values and the uint64 max sentinel systemd uses for "infinity" as zero.
+func parseSystemdUint(v string) uint64 {
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || n == ^uint64(0) {
+		return 0
+	}
+	return n
+}
+
+func parseSystemdTimestamp(v string) time.Time {
+	if v == "" || v == "n/a" {
+		return time.Time{}
+	}
+	t, err := time.ParseInLocation(systemdTimestampLayout, v, time.Local)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
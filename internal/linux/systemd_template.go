@@ -0,0 +1,94 @@
+package linux
+
+import (
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"strconv"
+	"strings"
+)
+
+var errNoInstance = errors.New("template service: pick an instance first")
+
+// IsTemplate reports whether the service is installed as a name@.service
+// template, see service.OptionTemplate.
+func (s *systemdService) IsTemplate() bool {
+	return s.Config.Option.Bool(service.OptionTemplate, service.OptionTemplateDefault)
+}
+
+// Instance returns the service bound to one instance of the template.
+// Arguments, EnvVars and WorkingDirectory can refer to it as %i.
+func (s *systemdService) Instance(name string) (service.Service, error) {
+	if !s.IsTemplate() {
+		return nil, fmt.Errorf("%s is not a template service", s.Name)
+	}
+	if name == "" {
+		return nil, errors.New("instance name is empty")
+	}
+	instance := *s
+	instance.instance = systemdEscape(name)
+	return &instance, nil
+}
+
+// Instances lists the running instances of the template.
+func (s *systemdService) Instances() ([]string, error) {
+	if !s.IsTemplate() {
+		return nil, fmt.Errorf("%s is not a template service", s.Name)
+	}
+	_, out, err := s.systemctl("list-units", "--type=service", "--state=running",
+		"--no-legend", "--plain", s.Config.Name+"@*.service")
+	if err != nil && !runners.IsExitError(err) {
+		return nil, err
+	}
+
+	prefix := s.Config.Name + "@"
+	var instances []string
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || !strings.HasPrefix(fields[0], prefix) {
+			continue
+		}
+		escaped := strings.TrimSuffix(strings.TrimPrefix(fields[0], prefix), ".service")
+		instances = append(instances, systemdUnescape(escaped))
+	}
+	return instances, nil
+}
+
+// systemdEscape escapes s for use in a unit name the way systemd-escape
+// does: "/" becomes "-" and anything outside [a-zA-Z0-9:_.] becomes \xNN.
+func systemdEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '/':
+			b.WriteByte('-')
+		case c == '.' && i == 0,
+			!(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == ':' || c == '_' || c == '.'):
+			fmt.Fprintf(&b, `\x%02x`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func systemdUnescape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '-' {
+			b.WriteByte('/')
+			continue
+		}
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,967 @@
+package linux
+
+import (
+	"errors"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeRunner struct {
+	calls    []string
+	outputs  map[string]string // Stdout keyed by the joined command line.
+	failures map[string]error  // Errors keyed by the command line prefix.
+}
+
+func (r *fakeRunner) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	line := strings.Join(append([]string{command}, arguments...), " ")
+	r.calls = append(r.calls, line)
+	for prefix, err := range r.failures {
+		if strings.HasPrefix(line, prefix) {
+			return 1, r.outputs[line], err
+		}
+	}
+	return 0, r.outputs[line], nil
+}
+
+type testController struct{ started, stopped bool }
+
+func (c *testController) Start(s service.Service) error { c.started = true; return nil }
+func (c *testController) Stop(s service.Service) error  { c.stopped = true; return nil }
+
+func newTestSystemdService(t *testing.T, c *service.Config) (*systemdService, *fakeRunner) {
+	if c.Executable == "" {
+		c.Executable = "/usr/bin/" + c.Name
+	}
+	r := &fakeRunner{outputs: map[string]string{"systemctl --version": "systemd 252 (252.22-1)\n"}}
+	var runner runners.Runner = r
+	svc, err := NewSystemdService(&testController{}, "linux-systemd", c, &runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc.(*systemdService), r
+}
+
+func TestSystemdUnitNotify(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "worker",
+		Option: service.KeyValue{service.OptionNotify: true, service.OptionWatchdogSec: "30s"},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Type=notify\n", "WatchdogSec=30s\n", "ExecStart=/usr/bin/worker\n"} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("Expected unit to contain %q:\n%s", want, unit)
+		}
+	}
+}
+
+func TestSystemdRunNotifies(t *testing.T) {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "worker",
+		Option: service.KeyValue{service.OptionRunWait: func() {}},
+	})
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	ctrl := s.i.(*testController)
+	if !ctrl.started || !ctrl.stopped {
+		t.Errorf("Expected controller to be started and stopped")
+	}
+
+	buf := make([]byte, 64)
+	for _, want := range []string{"READY=1", "STOPPING=1"} {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("Expected %q, got %q", want, buf[:n])
+		}
+	}
+}
+
+func TestSystemdSocketUnit(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name: "web",
+		Listen: []service.ListenAddress{
+			{Network: "tcp", Address: ":8080"},
+			{Network: "udp4", Address: ":53"},
+			{Network: "unix", Address: "/run/web.sock"},
+		},
+	})
+	socket, err := s.renderSocketUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ListenStream=8080\nListenDatagram=0.0.0.0:53\nListenStream=/run/web.sock\nService=web.service\n"
+	if !strings.Contains(string(socket), want) {
+		t.Errorf("Expected socket unit to contain %q:\n%s", want, socket)
+	}
+
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "Requires=web.socket\nAfter=web.socket\n") {
+		t.Errorf("Expected service unit to require its socket:\n%s", unit)
+	}
+	if strings.Contains(string(socket), "FileDescriptorName=") {
+		t.Errorf("Unexpected FileDescriptorName= without names:\n%s", socket)
+	}
+
+	s.Config.Listen = []service.ListenAddress{
+		{Network: "tcp", Address: ":80", Name: "http"},
+		{Network: "tcp", Address: ":8080", Name: "http"},
+	}
+	socket, _ = s.renderSocketUnit()
+	if !strings.Contains(string(socket), "ListenStream=8080\nFileDescriptorName=http\nService=web.service\n") {
+		t.Errorf("Expected FileDescriptorName=http:\n%s", socket)
+	}
+	s.Config.Listen[1].Name = "admin"
+	socket, _ = s.renderSocketUnit()
+	if strings.Contains(string(socket), "FileDescriptorName=") {
+		t.Errorf("Expected no FileDescriptorName= for differing names:\n%s", socket)
+	}
+	s.Config.Listen[1].Name = "a:b"
+	if _, err := s.renderSocketUnit(); err == nil {
+		t.Error("Expected a name with a colon to be rejected")
+	}
+}
+
+func TestSystemdScheduledService(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:     "backup",
+		Option:   service.KeyValue{service.OptionUserService: true},
+		Schedule: &service.Schedule{OnCalendar: "*-*-* 02:00:00", RandomizedDelaySec: "10min", Persistent: true},
+	})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := filepath.Join(home, ".config/systemd/user")
+	unit, err := os.ReadFile(filepath.Join(dir, "backup.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "Type=oneshot\n") || strings.Contains(string(unit), "Restart=") {
+		t.Errorf("Expected a oneshot service without Restart=:\n%s", unit)
+	}
+	if strings.Contains(string(unit), "[Install]") {
+		t.Errorf("Expected no [Install] section on a timer driven service:\n%s", unit)
+	}
+	timer, err := os.ReadFile(filepath.Join(dir, "backup.timer"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "OnCalendar=*-*-* 02:00:00\nRandomizedDelaySec=10min\nPersistent=true\nUnit=backup.service\n"
+	if !strings.Contains(string(timer), want) {
+		t.Errorf("Expected timer to contain %q:\n%s", want, timer)
+	}
+
+	r.outputs["systemctl --user show -p ActiveState -p LastTriggerUSec -p NextElapseUSecRealtime backup.timer"] =
+		"ActiveState=active\nLastTriggerUSec=n/a\nNextElapseUSecRealtime=Fri 2026-10-16 02:04:11 UTC\n"
+	d, err := s.StatusDetail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Status != service.StatusRunning || !d.LastTrigger.IsZero() || d.NextTrigger.Day() != 16 {
+		t.Errorf("Unexpected detail %+v", d)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"backup.service", "backup.timer"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+}
+
+type testJob struct {
+	testController
+	done chan struct{}
+}
+
+func (j *testJob) Done() <-chan struct{} { return j.done }
+
+func TestSystemdScheduledRunWaitsForJob(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:     "backup",
+		Schedule: &service.Schedule{OnCalendar: "daily"},
+	})
+	job := &testJob{done: make(chan struct{})}
+	s.i = job
+
+	errs := make(chan error)
+	go func() { errs <- s.Run() }()
+	select {
+	case <-errs:
+		t.Fatal("Run returned before the job was done")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(job.done)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if !job.started || !job.stopped {
+		t.Errorf("Expected the job to be started and stopped")
+	}
+}
+
+func TestSystemdTemplateService(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:             "worker",
+		Arguments:        []string{"--queue", "%i"},
+		WorkingDirectory: "/srv/worker/%i",
+		EnvVars:          map[string]string{"QUEUE": "%i"},
+		Option:           service.KeyValue{service.OptionTemplate: true},
+	})
+	if path, _ := s.ConfigPath(); path != "/etc/systemd/system/worker@.service" {
+		t.Errorf("Expected the template unit file, got %s", path)
+	}
+	if err := s.Start(); err != errNoInstance {
+		t.Errorf("Expected %v without an instance, got %v", errNoInstance, err)
+	}
+	if _, err := s.Status(); err != errNoInstance {
+		t.Errorf("Expected Status to fail with %v without an instance, got %v", errNoInstance, err)
+	}
+	if _, err := s.StatusDetail(); err != errNoInstance {
+		t.Errorf("Expected StatusDetail to fail with %v without an instance, got %v", errNoInstance, err)
+	}
+
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "--queue %i\n") || !strings.Contains(string(unit), "WorkingDirectory=/srv/worker/%i") ||
+		!strings.Contains(string(unit), "Environment=QUEUE=%i\n") {
+		t.Errorf("Expected %%i to reach the unit:\n%s", unit)
+	}
+
+	emails, err := s.Instance("emails/high")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := emails.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if last := r.calls[len(r.calls)-1]; last != `systemctl start worker@emails-high.service` {
+		t.Errorf("Unexpected call %q", last)
+	}
+
+	r.outputs["systemctl list-units --type=service --state=running --no-legend --plain worker@*.service"] =
+		"worker@emails-high.service loaded active running Worker\nworker@a\\x20b.service loaded active running Worker\n"
+	instances, err := s.Instances()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(instances, ",") != "emails/high,a b" {
+		t.Errorf("Unexpected instances %q", instances)
+	}
+}
+
+func TestSystemdUninstallInstance(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:   "worker",
+		Option: service.KeyValue{service.OptionUserService: true, service.OptionTemplate: true},
+	})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDropIn("memory", []byte("[Service]\nMemoryMax=1G\n")); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := s.Instance("a")
+	b, _ := s.Instance("b")
+	for _, instance := range []service.Service{a, b} {
+		if err := instance.Install(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r.calls = nil
+	if err := a.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(r.calls, "|"); got != "systemctl --user stop worker@a.service|systemctl --user disable worker@a.service" {
+		t.Errorf("Unexpected calls %q", got)
+	}
+	dir := filepath.Join(home, ".config/systemd/user")
+	for _, path := range []string{"worker@.service", "worker@.service.d/memory.conf"} {
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			t.Errorf("Expected %s to stay for instance b: %v", path, err)
+		}
+	}
+}
+
+func TestSystemdHardening(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionHardening: service.HardeningStrict},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"NoNewPrivileges=true\n", "ProtectSystem=strict\n", "CapabilityBoundingSet=\n", "SystemCallFilter=@system-service\n"} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("Expected unit to contain %q:\n%s", want, unit)
+		}
+	}
+
+	h := &service.Hardening{ProtectSystem: "strict", ProtectHome: "true", ReadWritePaths: []string{"/var/lib/api"}, AmbientCapabilities: []string{"CAP_NET_BIND_SERVICE"}}
+	lines, warnings := hardeningDirectives(h, 219)
+	want := "ProtectHome=true,ReadWriteDirectories=/var/lib/api"
+	if strings.Join(lines, ",") != want {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("Expected warnings for ProtectSystem=strict and AmbientCapabilities, got %q", warnings)
+	}
+
+	for _, tt := range []struct {
+		filter  []string
+		version int64
+		want    string
+	}{
+		{[]string{"@system-service"}, 235, ""},
+		{[]string{"@system-service"}, 239, "SystemCallFilter=@system-service"},
+		{[]string{"~@mount"}, 235, "SystemCallFilter=~@mount"},
+		{[]string{"@system-service", "~@setuid"}, 239, "SystemCallFilter=@system-service ~@setuid"},
+		{[]string{"@system-service", "@pkey"}, 243, ""},
+		{[]string{"@known"}, 246, ""},
+		{[]string{"@known"}, 247, "SystemCallFilter=@known"},
+		{[]string{"~@sandbox"}, 252, ""},
+		{[]string{"~@sandbox"}, -1, "SystemCallFilter=~@sandbox"},
+		{[]string{"@system-service", "@nonsense"}, 252, ""},
+		{[]string{"@nonsense"}, -1, ""},
+	} {
+		lines, warnings := hardeningDirectives(&service.Hardening{SystemCallFilter: tt.filter}, tt.version)
+		if strings.Join(lines, ",") != tt.want {
+			t.Errorf("%q on systemd %d: expected %q, got %q", tt.filter, tt.version, tt.want, lines)
+		}
+		if (tt.want == "") != (len(warnings) == 1) {
+			t.Errorf("%q on systemd %d: unexpected warnings %q", tt.filter, tt.version, warnings)
+		}
+	}
+
+	if _, err := service.HardeningPreset("paranoid"); err == nil {
+		t.Errorf("Expected an unknown preset to be rejected")
+	}
+}
+
+func TestSystemdResources(t *testing.T) {
+	nice := 5
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "batch",
+		Option: service.KeyValue{service.OptionLimitNOFILE: 1024},
+		Resources: &service.Resources{
+			MemoryMax: "512M",
+			CPUQuota:  "150%",
+			Nice:      &nice,
+			Limits:    map[string]string{"NOFILE": "4096:65536", "CORE": "infinity"},
+		},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "MemoryMax=512M\nCPUQuota=150%\nNice=5\nLimitCORE=infinity\nLimitNOFILE=4096:65536\n"
+	if !strings.Contains(string(unit), want) || strings.Contains(string(unit), "LimitNOFILE=1024") {
+		t.Errorf("Expected unit to contain %q only:\n%s", want, unit)
+	}
+
+	s.Config.Resources.CPUQuota = "1.5"
+	if _, err := s.renderUnit(); err == nil {
+		t.Errorf("Expected an invalid CPUQuota to be rejected")
+	}
+
+	r := resourcesFromProperties(map[string]string{
+		"MemoryMax": "536870912", "CPUQuotaPerSecUSec": "1.500000s", "Nice": "5",
+		"LimitNOFILE": "65536", "LimitNOFILESoft": "4096",
+	})
+	if r.CPUQuota != "150%" || *r.Nice != 5 || r.Limits["NOFILE"] != "4096:65536" {
+		t.Errorf("Unexpected resources %+v", r)
+	}
+	r = resourcesFromProperties(map[string]string{"MemoryMax": "infinity", "Nice": "0", "OOMScoreAdjust": "0"})
+	if r.Nice != nil || r.OOMScoreAdjust != nil {
+		t.Errorf("Expected the defaults to be left unset, got %+v", r)
+	}
+}
+
+func TestSystemdDropIns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionUserService: true},
+	})
+
+	if err := s.SetDropIn("memory", []byte("[Service]\nMemoryMax=1G\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDropIn("10-env.conf", []byte("[Service]\nEnvironment=DEBUG=1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetDropIn("../escape", nil); err == nil {
+		t.Errorf("Expected a drop-in name with a slash to be rejected")
+	}
+
+	names, err := s.DropIns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "10-env.conf,memory.conf" {
+		t.Errorf("Unexpected drop-ins %q", names)
+	}
+	content, err := os.ReadFile(filepath.Join(home, ".config/systemd/user/api.service.d/memory.conf"))
+	if err != nil || string(content) != "[Service]\nMemoryMax=1G\n" {
+		t.Errorf("Unexpected drop-in content %q: %v", content, err)
+	}
+
+	for _, name := range names {
+		if err := s.RemoveDropIn(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config/systemd/user/api.service.d")); !os.IsNotExist(err) {
+		t.Errorf("Expected the drop-in directory to be removed")
+	}
+	if last := r.calls[len(r.calls)-1]; !strings.Contains(last, "daemon-reload") {
+		t.Errorf("Expected a daemon-reload, got %q", last)
+	}
+}
+
+func TestSystemdUpgrade(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionUserService: true},
+	})
+
+	res, err := s.Upgrade(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || len(res.Files) != 1 || res.Restarted {
+		t.Errorf("Expected a fresh install, got %+v", res)
+	}
+
+	calls := len(r.calls)
+	res, err = s.Upgrade(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed {
+		t.Errorf("Expected nothing to change, got %+v", res)
+	}
+	for _, call := range r.calls[calls:] {
+		if !strings.Contains(call, "is-active") && !strings.Contains(call, "--version") {
+			t.Errorf("Unexpected call %q on an unchanged install", call)
+		}
+	}
+
+	r.outputs["systemctl --user is-active api.service"] = "active\n"
+	s.Config.Description = "API server"
+	res, err = s.Upgrade(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Changed || !res.Restarted {
+		t.Errorf("Expected the running service to be restarted, got %+v", res)
+	}
+}
+
+func TestSystemdRenderCustomScript(t *testing.T) {
+	// Scripts written for the original template use cmd and cmdEscape.
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:       "legacy",
+		Executable: "/opt/my app/legacy",
+		Arguments:  []string{"--msg", `say "hi"`},
+		Option: service.KeyValue{service.OptionSystemdScript: "[Service]\n" +
+			"ExecStart={{.Path|cmdEscape}}{{range .Arguments}} {{.|cmd}}{{end}}\n"},
+	})
+	rendering, err := service.Render(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ExecStart=/opt/my\ app/legacy "--msg" "say \"hi\""` + "\n"
+	if unit := string(rendering.Files[0].Content); !strings.Contains(unit, want) {
+		t.Errorf("Expected %q in unit:\n%s", want, unit)
+	}
+}
+
+func TestSystemdExecPath(t *testing.T) {
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "sh",
+		Option: service.KeyValue{service.OptionSystemdScript: "[Service]\nExecStart={{.Path}}\n"},
+	})
+	if path, err := s.ExecPath(); err != nil || path != "/usr/bin/sh" {
+		t.Errorf("Expected the configured Executable, got %q, %v", path, err)
+	}
+
+	// Without an Executable the binary is looked up by name; the custom
+	// script is the unit template, never the binary.
+	s.Config.Executable = ""
+	want, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not in PATH")
+	}
+	if path, err := s.ExecPath(); err != nil || path != want {
+		t.Errorf("Expected %q, got %q, %v", want, path, err)
+	}
+}
+
+func TestSystemdRender(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:     "backup",
+		ChRoot:   "/srv/root",
+		Schedule: &service.Schedule{OnCalendar: "daily"},
+		Option:   service.KeyValue{service.OptionPIDFile: "/run/backup.pid"},
+	})
+
+	rendering, err := service.Render(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rendering.Files) != 2 || rendering.Files[0].Path != "/etc/systemd/system/backup.service" || rendering.Files[1].Mode != 0644 {
+		t.Fatalf("Unexpected files %+v", rendering.Files)
+	}
+	unit := string(rendering.Files[0].Content)
+	if !strings.Contains(unit, "RootDirectory=/srv/root\n") || !strings.Contains(unit, "PIDFile=/run/backup.pid\n") {
+		t.Errorf("Unexpected unit:\n%s", unit)
+	}
+	var commands []string
+	for _, c := range rendering.Commands {
+		commands = append(commands, strings.Join(c, " "))
+	}
+	if strings.Join(commands, ",") != "systemctl enable backup.timer,systemctl daemon-reload" {
+		t.Errorf("Unexpected commands %q", commands)
+	}
+	for _, call := range r.calls {
+		if !strings.HasSuffix(call, "--version") {
+			t.Errorf("Render ran %q", call)
+		}
+	}
+
+	s.Config.Option[service.OptionSystemdScript] = "{{.Nope"
+	if _, err := s.Render(); err == nil {
+		t.Errorf("Expected a broken custom template to be reported")
+	}
+}
+
+func TestSystemdUserService(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SUDO_USER", "")
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:     "sync",
+		UserName: "alice",
+		Option:   service.KeyValue{service.OptionUserService: true, service.OptionLinger: true},
+	})
+	u, err := lookupSystemdUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	unit, err := os.ReadFile(filepath.Join(home, ".config/systemd/user/sync.service"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "WantedBy=default.target\n") || strings.Contains(string(unit), "User=") {
+		t.Errorf("Expected a default.target user unit without User=:\n%s", unit)
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	r.outputs["systemctl --user is-active sync.service"] = "active\n"
+	if status, err := s.Status(); err != nil || status != service.StatusRunning {
+		t.Errorf("Expected a running service, got %v, %v", status, err)
+	}
+
+	var calls []string
+	for _, call := range r.calls {
+		if !strings.HasSuffix(call, "--version") && !strings.HasPrefix(call, "systemd-analyze") {
+			calls = append(calls, call)
+		}
+	}
+	want := []string{
+		"loginctl enable-linger " + u.Username,
+		"systemctl --user enable sync.service",
+		"systemctl --user daemon-reload",
+		"systemctl --user start sync.service",
+		"systemctl --user is-active sync.service",
+	}
+	if strings.Join(calls, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected calls %q, got %q", want, calls)
+	}
+}
+
+func TestSystemdUserSessionEnv(t *testing.T) {
+	userRuntimeBase = t.TempDir()
+	defer func() { userRuntimeBase = "/run/user" }()
+	runtimeDir := filepath.Join(userRuntimeBase, "1000")
+	if err := os.MkdirAll(runtimeDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(runtimeDir, "bus"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+
+	if env := userSessionEnv(&systemdUser{Uid: 1000, Sudo: true}, true); env != nil {
+		t.Errorf("Expected no session environment under sudo, got %v", env)
+	}
+	env := userSessionEnv(&systemdUser{Uid: 1000}, false)
+	want := []string{"XDG_RUNTIME_DIR=" + runtimeDir, "DBUS_SESSION_BUS_ADDRESS=unix:path=" + runtimeDir + "/bus"}
+	if strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, env)
+	}
+	if got := sessionBusAddress(env); got != "unix:path="+runtimeDir+"/bus" {
+		t.Errorf("Unexpected session bus address %q", got)
+	}
+	if os.Getenv("XDG_RUNTIME_DIR") != "" || os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
+		t.Errorf("Expected the process environment to be left alone")
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+	if env := userSessionEnv(&systemdUser{Uid: 1000}, false); len(env) != 1 || !strings.HasPrefix(env[0], "DBUS_SESSION_BUS_ADDRESS=") {
+		t.Errorf("Expected only the missing bus address, got %v", env)
+	}
+
+	// Without --machine, root reaches the user's manager through their
+	// runtime directory, not its own.
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/0")
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path=/run/user/0/bus")
+	if env := userSessionEnv(&systemdUser{Uid: 1000, Sudo: true}, false); strings.Join(env, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v under sudo without --machine, got %v", want, env)
+	}
+}
+
+func TestSystemdUserServiceUnderSudo(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("sudo handling applies to root only")
+	}
+	t.Setenv("SUDO_USER", "nobody")
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:   "sync",
+		Option: service.KeyValue{service.OptionUserService: true, service.OptionSystemdDBus: true},
+	})
+	if s.dbus != nil {
+		t.Errorf("Expected systemctl instead of the session bus under sudo")
+	}
+	if got := strings.Join(s.systemctlArgs("start", "sync.service"), " "); got != "--user --machine=nobody@.host start sync.service" {
+		t.Errorf("Unexpected systemctl arguments %q", got)
+	}
+	u, err := lookupSystemdUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := s.ConfigPath(); path != filepath.Join(u.HomeDir, ".config/systemd/user/sync.service") {
+		t.Errorf("Expected the unit in the home of nobody, got %s", path)
+	}
+
+	// systemd before 248 has no --machine=<user>@.host.
+	r := &fakeRunner{outputs: map[string]string{"systemctl --version": "systemd 247 (247.3-7)\n"}}
+	var runner runners.Runner = r
+	svc, err := NewSystemdService(&testController{}, "linux-systemd", s.Config, &runner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(svc.(*systemdService).systemctlArgs("start", "sync.service"), " "); got != "--user start sync.service" {
+		t.Errorf("Unexpected systemctl arguments on systemd 247 %q", got)
+	}
+}
+
+func TestSystemdInstallVerifies(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionUserService: true},
+		Listen: []service.ListenAddress{{Network: "tcp", Address: ":8080"}},
+	})
+	r.failures = map[string]error{"systemd-analyze": &runners.ExitError{
+		Command: "systemd-analyze", ExitCode: 1, Stderr: "api.service: Unknown key name 'Bogus'",
+	}}
+
+	err := s.Install()
+	if err == nil || !strings.Contains(err.Error(), "Unknown key name 'Bogus'") {
+		t.Fatalf("Expected the verifier output in the error, got %v", err)
+	}
+	dir := filepath.Join(home, ".config/systemd/user")
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected nothing written, found %v", entries)
+	}
+	verify := strings.Fields(r.calls[len(r.calls)-1])
+	if len(verify) != 5 || verify[0] != "systemd-analyze" || verify[2] != "verify" ||
+		filepath.Base(verify[3]) != "api.service" || filepath.Base(verify[4]) != "api.socket" {
+		t.Errorf("Expected both units verified together, got %q", verify)
+	}
+	for _, call := range r.calls {
+		if strings.Contains(call, "enable") {
+			t.Errorf("Unexpected %q after a failed verification", call)
+		}
+	}
+
+	// Without systemd-analyze installation goes ahead.
+	r.failures = map[string]error{"systemd-analyze": errors.New("executable file not found in $PATH")}
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemdScheduleVerified(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:     "backup",
+		Option:   service.KeyValue{service.OptionUserService: true},
+		Schedule: &service.Schedule{OnCalendar: "daily", RandomizedDelaySec: "10 fortnights"},
+	})
+	r.failures = map[string]error{"systemd-analyze timespan 10 fortnights": &runners.ExitError{
+		Command: "systemd-analyze", ExitCode: 1, Stderr: "Failed to parse time span '10 fortnights'",
+	}}
+	err := s.Install()
+	if err == nil || !strings.Contains(err.Error(), "invalid schedule") {
+		t.Fatalf("Expected the schedule to be rejected, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(home, ".config/systemd/user")); len(entries) != 0 {
+		t.Errorf("Expected nothing written, found %v", entries)
+	}
+	var checked []string
+	for _, call := range r.calls {
+		if strings.HasPrefix(call, "systemd-analyze calendar") || strings.HasPrefix(call, "systemd-analyze timespan") {
+			checked = append(checked, call)
+		}
+	}
+	if strings.Join(checked, ", ") != "systemd-analyze calendar daily, systemd-analyze timespan 10 fortnights" {
+		t.Errorf("Unexpected schedule checks %q", checked)
+	}
+
+	s.Config.Schedule = &service.Schedule{OnCalendar: "daily\nExecStartPre=/bin/sh"}
+	if _, err := s.Render(); err == nil || !strings.Contains(err.Error(), "control character") {
+		t.Errorf("Expected a control character to be rejected, got %v", err)
+	}
+}
+
+func TestSystemdInstallRollsBack(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:   "api",
+		Option: service.KeyValue{service.OptionUserService: true},
+		Listen: []service.ListenAddress{{Network: "tcp", Address: ":8080"}},
+	})
+	r.failures = map[string]error{"systemctl --user daemon-reload": &runners.ExitError{Command: "systemctl", ExitCode: 1}}
+
+	if err := s.Install(); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Fatalf("Expected a rolled back install, got %v", err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(home, ".config/systemd/user")); len(entries) != 0 {
+		t.Errorf("Expected the unit files removed, found %v", entries)
+	}
+	var disabled []string
+	for _, call := range r.calls {
+		if strings.HasPrefix(call, "systemctl --user disable ") {
+			disabled = append(disabled, strings.TrimPrefix(call, "systemctl --user disable "))
+		}
+	}
+	if strings.Join(disabled, " ") != "api.socket api.service" {
+		t.Errorf("Expected both units disabled again, got %q", disabled)
+	}
+}
+
+func TestSystemdEnvironmentFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	s, _ := newTestSystemdService(t, &service.Config{
+		Name:          "api",
+		Option:        service.KeyValue{service.OptionUserService: true},
+		EnvVars:       map[string]string{"PORT": "8080"},
+		SecretEnvVars: map[string]string{"DB_PASSWORD": "hunter2"},
+	})
+	envPath := filepath.Join(home, ".config/systemd/user/api.env")
+
+	rendering, err := s.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := rendering.Files[len(rendering.Files)-1]
+	if last.Path != envPath || last.Mode != 0600 || strings.Contains(string(last.Content), "hunter2") {
+		t.Errorf("Expected a masked environment file in the preview, got %+v", last)
+	}
+
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	unit, _ := os.ReadFile(filepath.Join(home, ".config/systemd/user/api.service"))
+	if !strings.Contains(string(unit), "EnvironmentFile=-"+envPath+"\n") || !strings.Contains(string(unit), "Environment=PORT=8080\n") || strings.Contains(string(unit), "hunter2") {
+		t.Errorf("Expected the unit to set PORT and load the secrets from the environment file:\n%s", unit)
+	}
+	env, err := os.ReadFile(envPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(env), "\nDB_PASSWORD=\"hunter2\"\n") {
+		t.Errorf("Unexpected environment file:\n%s", env)
+	}
+	if info, _ := os.Stat(envPath); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	s.Config.SecretEnvVars["DB_PASSWORD"] = "correct horse"
+	res, err := s.Upgrade(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Files) != 1 || res.Files[0] != envPath {
+		t.Errorf("Expected only the environment file rewritten, got %+v", res)
+	}
+
+	if err := s.Uninstall(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(envPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the environment file removed, got %v", err)
+	}
+}
+
+func TestSystemdCredentials(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{
+		Name: "api",
+		Credentials: []service.Credential{
+			{Name: "db", Path: "/etc/api/db.password"},
+			{Name: "tls", Path: "/etc/credstore.encrypted/tls", Encrypted: true},
+			{Name: "token", Value: " 50% off\\now\n"},
+		},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"LoadCredential=db:/etc/api/db.password\n",
+		"LoadCredentialEncrypted=tls:/etc/credstore.encrypted/tls\n",
+		`SetCredential=token:\x2050%% off\\now\n` + "\n",
+	} {
+		if !strings.Contains(string(unit), want) {
+			t.Errorf("Expected %q in unit:\n%s", want, unit)
+		}
+	}
+
+	r.outputs["systemctl --version"] = "systemd 249\n"
+	if _, err := s.renderUnit(); err == nil || !strings.Contains(err.Error(), "LoadCredentialEncrypted") {
+		t.Errorf("Expected encrypted credentials to need systemd 250, got %v", err)
+	}
+	s.Config.Credentials = []service.Credential{{Name: "db"}}
+	if _, err := s.renderUnit(); err == nil {
+		t.Error("Expected a credential without Path or Value to be rejected")
+	}
+}
+
+func TestSystemdRunTransient(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:             "reindex",
+		Description:      "Rebuild the index",
+		Arguments:        []string{"--full", "two words"},
+		WorkingDirectory: "/srv/index",
+		UserName:         "index",
+		EnvVars:          map[string]string{"B": "2", "A": "1"},
+		Resources:        &service.Resources{MemoryMax: "1G"},
+	})
+
+	res, err := service.RunTransient(s, service.TransientOptions{Unit: "reindex-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Unit != "reindex-1.service" || res.Result != "" {
+		t.Errorf("Unexpected result %+v", res)
+	}
+	want := "systemd-run --unit=reindex-1.service --description=Rebuild the index --collect" +
+		" --property=WorkingDirectory=/srv/index --property=User=index --property=MemoryMax=1G" +
+		" --setenv=A=1 --setenv=B=2 -- /usr/bin/reindex --full two words"
+	if got := r.calls[len(r.calls)-1]; got != want {
+		t.Errorf("Expected\n%s\ngot\n%s", want, got)
+	}
+
+	r.failures = map[string]error{"systemd-run": &runners.ExitError{
+		Command:  "systemd-run",
+		ExitCode: 3,
+		Stderr:   "Running as unit: reindex-2.service\nFinished with result: exit-code\nMain processes terminated with: code=exited/status=3\n",
+	}}
+	res, err = s.RunTransient(service.TransientOptions{Unit: "reindex-2.service", Wait: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Success() || res.Result != "exit-code" || res.ExitStatus != 3 {
+		t.Errorf("Expected exit status 3, got %+v", res)
+	}
+	if last := r.calls[len(r.calls)-1]; last != "systemctl reset-failed reindex-2.service" {
+		t.Errorf("Expected the failed unit to be reset, got %q", last)
+	}
+
+	r.failures["systemd-run"] = &runners.ExitError{Command: "systemd-run", ExitCode: 1, Stderr: "Unknown assignment: Bogus=1\n"}
+	if _, err := s.RunTransient(service.TransientOptions{Wait: true}); err == nil || !strings.Contains(err.Error(), "Bogus") {
+		t.Errorf("Expected the systemd-run failure, got %v", err)
+	}
+
+	delete(r.failures, "systemd-run")
+	res, err = s.RunTransient(service.TransientOptions{Wait: true})
+	if err != nil || !res.Success() || !strings.HasPrefix(res.Unit, "reindex-") {
+		t.Errorf("Expected a successful run, got %+v, %v", res, err)
+	}
+	again, _ := s.RunTransient(service.TransientOptions{})
+	if again.Unit == res.Unit {
+		t.Errorf("Expected runs started in the same second to get distinct units, got %s twice", res.Unit)
+	}
+}
+
+func TestSystemdRunTransientPercent(t *testing.T) {
+	s, r := newTestSystemdService(t, &service.Config{
+		Name:        "report",
+		Credentials: []service.Credential{{Name: "banner", Value: "50% off"}},
+	})
+	unit, err := s.renderUnit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(unit), "SetCredential=banner:50%% off\n") {
+		t.Errorf("Expected %% to be escaped in the unit:\n%s", unit)
+	}
+
+	if _, err := s.RunTransient(service.TransientOptions{Unit: "report-1"}); err != nil {
+		t.Fatal(err)
+	}
+	last := r.calls[len(r.calls)-1]
+	if !strings.Contains(last, "--property=SetCredential=banner:50% off ") {
+		t.Errorf("Expected a literal %% in the property, got %s", last)
+	}
+}
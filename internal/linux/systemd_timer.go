@@ -0,0 +1,78 @@
+package linux
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/faelmori/keepgo/service"
+	"text/template"
+)
+
+type systemdTimerData struct {
+	*service.Schedule
+	Name string
+	Unit string
+}
+
+func (s *systemdService) TimerUnitName() string { return s.Config.Name + ".timer" }
+
+// IsScheduled reports whether the service runs from a timer.
+func (s *systemdService) IsScheduled() bool { return s.Config.Schedule != nil }
+
+func (s *systemdService) renderTimerUnit() ([]byte, error) {
+	sched := s.Config.Schedule
+	if sched.OnCalendar == "" && sched.OnBootSec == "" {
+		return nil, fmt.Errorf("schedule for %s needs OnCalendar or OnBootSec", s.Name)
+	}
+	for _, v := range []string{sched.OnCalendar, sched.OnBootSec, sched.RandomizedDelaySec} {
+		if hasControl(v) {
+			return nil, fmt.Errorf("schedule for %s: invalid control character in %q", s.Name, v)
+		}
+	}
+
+	var buf bytes.Buffer
+	data := &systemdTimerData{Schedule: sched, Name: s.Config.Name, Unit: s.UnitName()}
+	err := template.Must(template.New("").Funcs(TF).Parse(systemdTimerScript)).Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// timerDetail fills in the trigger times of a scheduled service.
+func (s *systemdService) timerDetail(d *service.StatusDetail) error {
+	props, err := s.showProperties(s.TimerUnitName(), "ActiveState", "LastTriggerUSec", "NextElapseUSecRealtime")
+	if err != nil {
+		return err
+	}
+	d.LastTrigger = parseSystemdTimestamp(props["LastTriggerUSec"])
+	d.NextTrigger = parseSystemdTimestamp(props["NextElapseUSecRealtime"])
+	// Between runs the service is inactive; whether the timer is armed is
+	// what tells a scheduled service apart from a stopped one.
+	if props["ActiveState"] == "active" {
+		d.Status = service.StatusRunning
+	} else {
+		d.Status = service.StatusStopped
+	}
+	for k, v := range props {
+		d.Properties["Timer"+k] = v
+	}
+	return nil
+}
+
+const systemdTimerScript = `[Unit]
+Description={{.Name}} timer
+
+[Timer]
+{{if .OnCalendar}}OnCalendar={{.OnCalendar}}
+{{end -}}
+{{if .OnBootSec}}OnBootSec={{.OnBootSec}}
+{{end -}}
+{{if .RandomizedDelaySec}}RandomizedDelaySec={{.RandomizedDelaySec}}
+{{end -}}
+{{if .Persistent}}Persistent=true
+{{end -}}
+Unit={{.Unit}}
+
+[Install]
+WantedBy=timers.target
+`
@@ -0,0 +1,152 @@
+package linux
+
+import (
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"math/rand/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// What systemd-run --wait reports about the finished job.
+var (
+	transientResultPattern = regexp.MustCompile(`Finished with result: (\S+)`)
+	transientExitPattern   = regexp.MustCompile(`Main processes terminated with: code=\w+/status=(\w+)`)
+)
+
+// RunTransient implements service.TransientRunner through systemd-run. The
+// unit gets the Config's resources, hardening, dependencies, credentials and
+// environment as properties; SecretEnvVars are only available when the
+// environment file was written, as they never go on a command line.
+func (s *systemdService) RunTransient(opts service.TransientOptions) (service.TransientResult, error) {
+	result := service.TransientResult{Unit: opts.Unit}
+	if result.Unit == "" {
+		// The random part keeps runs started within the same second apart.
+		result.Unit = fmt.Sprintf("%s-%d-%08x", s.Config.Name, time.Now().Unix(), rand.Uint32())
+	}
+	if !strings.HasSuffix(result.Unit, ".service") {
+		result.Unit += ".service"
+	}
+
+	args, err := s.transientArgs(result.Unit, opts.Wait)
+	if err != nil {
+		return result, err
+	}
+	// A failed run of the same name would block the unit name.
+	_ = s.run("reset-failed", result.Unit)
+
+	_, _, err = s.RunWithOutput("systemd-run", args...)
+	if !opts.Wait {
+		return result, err
+	}
+
+	var exitErr *runners.ExitError
+	if err == nil {
+		result.Result = "success"
+		return result, nil
+	}
+	if !errors.As(err, &exitErr) {
+		return result, err
+	}
+	m := transientResultPattern.FindStringSubmatch(exitErr.Stderr)
+	if m == nil {
+		// systemd-run itself failed, the job never ran.
+		return result, err
+	}
+	result.Result, result.ExitStatus = m[1], -1
+	if m := transientExitPattern.FindStringSubmatch(exitErr.Stderr); m != nil {
+		if status, err := strconv.Atoi(m[1]); err == nil {
+			result.ExitStatus = status
+		}
+	}
+	_ = s.run("reset-failed", result.Unit)
+	return result, nil
+}
+
+// transientArgs maps the Config onto a systemd-run command line.
+func (s *systemdService) transientArgs(unit string, wait bool) ([]string, error) {
+	path, err := s.ExecPath()
+	if err != nil {
+		return nil, err
+	}
+	version := s.GetSystemdVersion()
+
+	args := append(s.systemctlScope(), "--unit="+unit)
+	if s.Config.Description != "" {
+		args = append(args, "--description="+s.Config.Description)
+	}
+	if wait {
+		args = append(args, "--wait")
+	} else if version == -1 || version >= 236 {
+		// Unload the unit even when it fails, nothing waits for its result.
+		args = append(args, "--collect")
+	}
+
+	var props []string
+	if s.Config.WorkingDirectory != "" {
+		props = append(props, "WorkingDirectory="+s.Config.WorkingDirectory)
+	}
+	if s.Config.ChRoot != "" {
+		props = append(props, "RootDirectory="+s.Config.ChRoot)
+	}
+	if s.Config.UserName != "" && !s.IsUserService() {
+		props = append(props, "User="+s.Config.UserName)
+	}
+	if len(s.Config.SecretEnvVars) > 0 {
+		envFile, err := s.EnvironmentFile()
+		if err != nil {
+			return nil, err
+		}
+		props = append(props, "EnvironmentFile=-"+envFile)
+	}
+
+	hardening, err := s.hardening()
+	if err != nil {
+		return nil, err
+	}
+	hardeningProps, warnings := hardeningDirectives(hardening, version)
+	s.warn(warnings)
+	resources, err := resourceDirectives(s.Config.Resources)
+	if err != nil {
+		return nil, err
+	}
+	dependencies, err := systemdDependencyDirectives(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	credentials, err := credentialDirectives(s.Config.Credentials, version)
+	if err != nil {
+		return nil, err
+	}
+	// The directives are rendered for unit files, where every % is doubled
+	// to escape specifiers. systemd-run sets properties as they are, so the
+	// doubling is undone.
+	for _, group := range [][]string{hardeningProps, resources, dependencies, credentials} {
+		for _, p := range group {
+			props = append(props, strings.ReplaceAll(p, "%%", "%"))
+		}
+	}
+	for _, p := range props {
+		args = append(args, "--property="+p)
+	}
+
+	names := make([]string, 0, len(s.Config.EnvVars))
+	for k := range s.Config.EnvVars {
+		if !envNamePattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid environment variable name %q", k)
+		}
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		args = append(args, "--setenv="+k+"="+s.Config.EnvVars[k])
+	}
+
+	args = append(args, "--", path)
+	return append(args, s.Config.Arguments...), nil
+}
@@ -0,0 +1,123 @@
+package linux
+
+import (
+	"bytes"
+	"github.com/faelmori/keepgo/service"
+	"os"
+)
+
+// Upgrade installs the service or brings an existing installation up to
+// date with the current Config. Unit files are only rewritten when their
+// content differs, newly created units are enabled and, when restart is set,
+// a running service is restarted to pick up the changes.
+func (s *systemdService) Upgrade(restart bool) (service.InstallResult, error) {
+	var result service.InstallResult
+
+	units, err := s.renderUnits()
+	if err != nil {
+		return result, err
+	}
+
+	running := false
+	if restart {
+		status, err := s.Status()
+		running = err == nil && status == service.StatusRunning
+	}
+
+	var changed []renderedUnit
+	var created []systemdUnit
+	for _, u := range units {
+		current, err := os.ReadFile(u.Path)
+		switch {
+		case err == nil && bytes.Equal(current, u.Content):
+			continue
+		case os.IsNotExist(err):
+			created = append(created, u.systemdUnit)
+		case err != nil:
+			return result, err
+		}
+		changed = append(changed, u)
+	}
+	envPath, envChanged, err := s.environmentChanged()
+	if err != nil {
+		return result, err
+	}
+	if len(changed) == 0 && !envChanged {
+		return result, nil
+	}
+	if len(changed) > 0 {
+		err = s.verifyUnits(units)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	rollback := &unitRollback{s: s}
+	for _, u := range changed {
+		err = rollback.write(u.Path, u.Content)
+		if err != nil {
+			return result, rollback.undo(err)
+		}
+	}
+	if envChanged {
+		err = rollback.writeEnvironment()
+		if err != nil {
+			return result, rollback.undo(err)
+		}
+	}
+	err = s.enableLinger()
+	if err != nil {
+		return result, rollback.undo(err)
+	}
+	// Leave the enablement of existing units to the operator.
+	for _, u := range created {
+		if !u.Enable {
+			continue
+		}
+		err = rollback.enable(u.Name)
+		if err != nil {
+			return result, rollback.undo(err)
+		}
+	}
+	err = s.run("daemon-reload")
+	if err != nil {
+		return result, rollback.undo(err)
+	}
+	for _, u := range changed {
+		result.Files = append(result.Files, u.Path)
+	}
+	if envChanged {
+		result.Files = append(result.Files, envPath)
+	}
+	result.Changed = true
+
+	if running {
+		err = s.Restart()
+		if err != nil {
+			return result, err
+		}
+		result.Restarted = true
+	}
+	return result, nil
+}
+
+// environmentChanged reports whether the environment file differs from
+// what the Config asks for.
+func (s *systemdService) environmentChanged() (string, bool, error) {
+	if !s.hasEnvironment() {
+		return "", false, nil
+	}
+	path, err := s.EnvironmentFile()
+	if err != nil {
+		return "", false, err
+	}
+	content, err := s.environment()
+	if err != nil {
+		return "", false, err
+	}
+	current, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", false, err
+	}
+	return path, err != nil || !bytes.Equal(current, content), nil
+}
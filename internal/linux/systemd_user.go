@@ -0,0 +1,192 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// userRuntimeBase holds the per-user runtime directories, /run/user/<uid>.
+var userRuntimeBase = "/run/user"
+
+// systemdUser is the account whose user manager runs a user service. Run as
+// root through sudo, that is the invoking user rather than root.
+type systemdUser struct {
+	Username string
+	Uid, Gid int
+	HomeDir  string
+	Sudo     bool // Acting as root on behalf of SUDO_USER.
+}
+
+func lookupSystemdUser() (*systemdUser, error) {
+	if name := os.Getenv("SUDO_USER"); os.Geteuid() == 0 && name != "" && name != "root" {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		su, err := newSystemdUser(u, u.HomeDir)
+		if err != nil {
+			return nil, err
+		}
+		su.Sudo = true
+		return su, nil
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	return newSystemdUser(u, home)
+}
+
+func newSystemdUser(u *user.User, home string) (*systemdUser, error) {
+	uid, err := strconv.Atoi(u.Uid)
+	if err != nil {
+		return nil, err
+	}
+	gid, err := strconv.Atoi(u.Gid)
+	if err != nil {
+		return nil, err
+	}
+	return &systemdUser{Username: u.Username, Uid: uid, Gid: gid, HomeDir: home}, nil
+}
+
+// systemctlScope returns the flags selecting the service manager: none for
+// the system manager, --user for the own user manager and, under sudo, the
+// invoking user's manager through --machine where systemd supports it.
+func (s *systemdService) systemctlScope() []string {
+	if !s.IsUserService() {
+		return nil
+	}
+	u, err := lookupSystemdUser()
+	if err == nil && u.Sudo && s.machine {
+		return []string{"--user", "--machine=" + u.Username + "@.host"}
+	}
+	return []string{"--user"}
+}
+
+// machineScopeSince is the systemd release whose systemctl reaches another
+// user's manager with --machine=<user>@.host.
+const machineScopeSince = 248
+
+// systemctl runs systemctl against the manager owning the service.
+func (s *systemdService) systemctl(args ...string) (int, string, error) {
+	return s.RunWithOutput("systemctl", append(s.systemctlScope(), args...)...)
+}
+
+// userSessionEnv returns XDG_RUNTIME_DIR and DBUS_SESSION_BUS_ADDRESS
+// assignments for those missing from the environment, as under cron or a
+// bare su, so systemctl --user and the D-Bus backend find the user manager.
+// They are handed to the commands run and the bus dialled rather than set
+// in the process environment. Under sudo, those of the user replace root's
+// unless machine is set and systemctl reaches the user through --machine.
+func userSessionEnv(u *systemdUser, machine bool) []string {
+	if u.Sudo && machine {
+		return nil
+	}
+	var env []string
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" || u.Sudo {
+		dir := filepath.Join(userRuntimeBase, strconv.Itoa(u.Uid))
+		if _, err := os.Stat(dir); err != nil {
+			return nil
+		}
+		runtimeDir = dir
+		env = append(env, "XDG_RUNTIME_DIR="+runtimeDir)
+	}
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" || u.Sudo {
+		bus := filepath.Join(runtimeDir, "bus")
+		if _, err := os.Stat(bus); err == nil {
+			env = append(env, "DBUS_SESSION_BUS_ADDRESS=unix:path="+bus)
+		}
+	}
+	return env
+}
+
+// sessionBusAddress returns the DBUS_SESSION_BUS_ADDRESS assignment in env,
+// empty when there is none.
+func sessionBusAddress(env []string) string {
+	for _, kv := range env {
+		if addr, ok := strings.CutPrefix(kv, "DBUS_SESSION_BUS_ADDRESS="); ok {
+			return addr
+		}
+	}
+	return ""
+}
+
+// mkdirOwned creates dir and its missing parents. For user services set up
+// through sudo the new directories are handed to the user, as their files
+// are by chownUserFile.
+func (s *systemdService) mkdirOwned(dir string) error {
+	var missing []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || d == filepath.Dir(d) {
+			break
+		}
+		missing = append(missing, d)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, d := range missing {
+		if err := s.chownUserFile(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *systemdService) chownUserFile(path string) error {
+	if !s.IsUserService() {
+		return nil
+	}
+	u, err := lookupSystemdUser()
+	if err != nil || !u.Sudo {
+		return err
+	}
+	return os.Chown(path, u.Uid, u.Gid)
+}
+
+// writeUnitFile writes a unit file, creating the unit directory on first use.
+func (s *systemdService) writeUnitFile(path string, content []byte) error {
+	if err := s.mkdirOwned(filepath.Dir(path)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return err
+	}
+	return s.chownUserFile(path)
+}
+
+// lingerCommand is the loginctl call keeping the user manager, and so the
+// service, running while the user is logged out.
+func (s *systemdService) lingerCommand() ([]string, error) {
+	u, err := lookupSystemdUser()
+	if err != nil {
+		return nil, err
+	}
+	return []string{"loginctl", "enable-linger", u.Username}, nil
+}
+
+// enableLinger runs lingerCommand when OptionLinger is set.
+func (s *systemdService) enableLinger() error {
+	if !s.lingers() {
+		return nil
+	}
+	cmd, err := s.lingerCommand()
+	if err != nil {
+		return err
+	}
+	return s.runCommand(cmd[0], cmd[1:]...)
+}
+
+func (s *systemdService) lingers() bool {
+	return s.IsUserService() && s.Config.Option.Bool(service.OptionLinger, service.OptionLingerDefault)
+}
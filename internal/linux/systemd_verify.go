@@ -0,0 +1,172 @@
+package linux
+
+import (
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"os"
+	"path/filepath"
+)
+
+// renderedUnit is a unit file ready to be written.
+type renderedUnit struct {
+	systemdUnit
+	Path    string
+	Content []byte
+}
+
+// renderUnits renders every unit of the service without touching the system.
+func (s *systemdService) renderUnits() ([]renderedUnit, error) {
+	units, err := s.units()
+	if err != nil {
+		return nil, err
+	}
+	rendered := make([]renderedUnit, 0, len(units))
+	for _, u := range units {
+		content, err := u.render()
+		if err != nil {
+			return nil, err
+		}
+		path, err := s.unitPath(u.File)
+		if err != nil {
+			return nil, err
+		}
+		rendered = append(rendered, renderedUnit{u, path, content})
+	}
+	return rendered, nil
+}
+
+// verifyUnits runs systemd-analyze verify on a staged copy of units, so a
+// broken unit never reaches the unit directory. Hosts without
+// systemd-analyze skip the check.
+func (s *systemdService) verifyUnits(units []renderedUnit) error {
+	dir, err := os.MkdirTemp("", "keepgo-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	args := []string{"verify"}
+	if s.IsUserService() {
+		args = []string{"--user", "verify"}
+	}
+	for _, u := range units {
+		name := u.File
+		if s.IsTemplate() && name == s.unitFileName() {
+			// Templates can only be verified through an instance.
+			name = s.Config.Name + "@verify.service"
+			if s.instance != "" {
+				name = s.UnitName()
+			}
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, u.Content, 0644); err != nil {
+			return err
+		}
+		args = append(args, path)
+	}
+
+	_, _, err = s.RunWithOutput("systemd-analyze", args...)
+	if err != nil && runners.IsExitError(err) {
+		return fmt.Errorf("%s: unit verification failed: %v", s.Name, err)
+	}
+	return s.verifySchedule()
+}
+
+// verifySchedule has systemd-analyze parse the time values of the timer,
+// which verify accepts even when systemd would ignore them.
+func (s *systemdService) verifySchedule() error {
+	sched := s.Config.Schedule
+	if sched == nil {
+		return nil
+	}
+	checks := [][]string{}
+	if sched.OnCalendar != "" {
+		checks = append(checks, []string{"calendar", sched.OnCalendar})
+	}
+	for _, v := range []string{sched.OnBootSec, sched.RandomizedDelaySec} {
+		if v != "" {
+			checks = append(checks, []string{"timespan", v})
+		}
+	}
+	for _, args := range checks {
+		_, _, err := s.RunWithOutput("systemd-analyze", args...)
+		if err != nil && runners.IsExitError(err) {
+			return fmt.Errorf("%s: invalid schedule: %v", s.Name, err)
+		}
+	}
+	return nil
+}
+
+// unitRollback records what an install changed so a failed install can be
+// undone instead of leaving a half configured service behind.
+type unitRollback struct {
+	s       *systemdService
+	files   []savedUnitFile
+	enabled []string
+}
+
+type savedUnitFile struct {
+	path    string
+	content []byte // Content before the install, nil when the file was new.
+	env     bool   // The environment file rather than a unit.
+}
+
+func (r *unitRollback) write(path string, content []byte) error {
+	previous, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := r.s.writeUnitFile(path, content); err != nil {
+		return err
+	}
+	r.files = append(r.files, savedUnitFile{path, previous, false})
+	return nil
+}
+
+// writeEnvironment writes the environment file from the Config.
+func (r *unitRollback) writeEnvironment() error {
+	path, err := r.s.EnvironmentFile()
+	if err != nil {
+		return err
+	}
+	previous, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := r.s.WriteEnvironment(); err != nil {
+		return err
+	}
+	r.files = append(r.files, savedUnitFile{path, previous, true})
+	return nil
+}
+
+func (r *unitRollback) enable(unit string) error {
+	if err := r.s.run("enable", unit); err != nil {
+		return err
+	}
+	r.enabled = append(r.enabled, unit)
+	return nil
+}
+
+// undo reverts the recorded changes, newest first, and returns err annotated
+// with the rollback. Failures while rolling back are not reported over err.
+func (r *unitRollback) undo(err error) error {
+	for i := len(r.enabled) - 1; i >= 0; i-- {
+		_ = r.s.run("disable", r.enabled[i])
+	}
+	for i := len(r.files) - 1; i >= 0; i-- {
+		f := r.files[i]
+		switch {
+		case f.content == nil:
+			_ = os.Remove(f.path)
+		case f.env:
+			_ = r.s.writeEnvironmentFile(f.path, f.content)
+		default:
+			_ = r.s.writeUnitFile(f.path, f.content)
+		}
+	}
+	if len(r.files) > 0 {
+		_ = r.s.run("daemon-reload")
+	}
+	return fmt.Errorf("%s: install rolled back: %w", r.s.Name, err)
+}
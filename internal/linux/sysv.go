@@ -0,0 +1,315 @@
+package linux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/faelmori/keepgo/runners"
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// sysvInitDir is where SysV init scripts live.
+var sysvInitDir = "/etc/init.d"
+
+// sysvLookPath finds the tools enabling init scripts.
+var sysvLookPath = exec.LookPath
+
+func NewSysVService(i service.Controller, platform string, c *service.Config, r *runners.Runner) (service.Service, error) {
+	s := &sysvService{}
+	s.initService = newInitService(i, platform, c, r, initEnv{path: s.environmentFile})
+	return s, nil
+}
+
+type sysvService struct {
+	initService
+}
+
+type sysvData struct {
+	*service.Config
+	Deps            lsbDependencies
+	User            string
+	Daemon          string
+	EnvironmentFile string
+}
+
+func (s *sysvService) Run() error { return s.runService(s) }
+func (s *sysvService) Install() error {
+	script, err := s.renderScript()
+	if err != nil {
+		return err
+	}
+	enable, _, err := s.enableCommands()
+	if err != nil {
+		return err
+	}
+	return s.install(s.ConfigPath(), script, 0755, func() error {
+		return s.runCommand(enable[0], enable[1:]...)
+	})
+}
+func (s *sysvService) Uninstall() error {
+	_, disable, err := s.enableCommands()
+	if err != nil {
+		return err
+	}
+	err = s.runCommand(disable[0], disable[1:]...)
+	if err != nil {
+		return err
+	}
+	return s.removeFiles(s.ConfigPath())
+}
+func (s *sysvService) Platform() string { return "sysv" }
+
+// Status maps the LSB exit codes of the script's status action: 0 running,
+// 1 and 2 dead with a stale pid or lock file, which is stopped too, 3 not
+// running, 4 unknown.
+func (s *sysvService) Status() (service.Status, error) {
+	if _, err := os.Stat(s.ConfigPath()); os.IsNotExist(err) {
+		return service.StatusUnknown, service.ErrNotInstalled
+	}
+	_, _, err := s.RunWithOutput(s.ConfigPath(), "status")
+	if err == nil {
+		return service.StatusRunning, nil
+	}
+	var exitErr *runners.ExitError
+	if !errors.As(err, &exitErr) {
+		return service.StatusUnknown, err
+	}
+	switch exitErr.ExitCode {
+	case 1, 2, 3:
+		return service.StatusStopped, nil
+	default:
+		return service.StatusUnknown, err
+	}
+}
+func (s *sysvService) Start() error   { return s.runCommand(s.ConfigPath(), "start") }
+func (s *sysvService) Stop() error    { return s.runCommand(s.ConfigPath(), "stop") }
+func (s *sysvService) Restart() error { return s.runCommand(s.ConfigPath(), "restart") }
+
+// Logs implements service.LogReader, following the files the script
+// redirects the daemon's output to.
+func (s *sysvService) Logs(ctx context.Context, opts service.LogOptions) (service.LogStream, error) {
+	return fileLogs(ctx, []logFile{
+		{"/var/log/" + s.Name + ".log", service.PriorityInfo},
+		{"/var/log/" + s.Name + ".err", service.PriorityErr},
+	}, opts), nil
+}
+
+// Render returns the init script, environment file and commands a fresh
+// Install would produce. Secret environment values are masked.
+func (s *sysvService) Render() (*service.Rendering, error) {
+	script, err := s.renderScript()
+	if err != nil {
+		return nil, err
+	}
+	enable, _, err := s.enableCommands()
+	if err != nil {
+		return nil, err
+	}
+	return s.render(s.ConfigPath(), script, 0755, enable)
+}
+
+// enableCommands returns the commands adding the script to the runlevels
+// and removing it, with update-rc.d on Debian style systems and chkconfig
+// on Red Hat style ones.
+func (s *sysvService) enableCommands() (enable, disable []string, err error) {
+	if _, err := sysvLookPath("update-rc.d"); err == nil {
+		return []string{"update-rc.d", s.Name, "defaults"}, []string{"update-rc.d", "-f", s.Name, "remove"}, nil
+	}
+	if _, err := sysvLookPath("chkconfig"); err == nil {
+		return []string{"chkconfig", "--add", s.Name}, []string{"chkconfig", "--del", s.Name}, nil
+	}
+	return nil, nil, errors.New("neither update-rc.d nor chkconfig found")
+}
+
+// environmentFile is the file the init script sources before starting the
+// daemon.
+func (s *sysvService) environmentFile() (string, error) {
+	return filepath.Join(envFileDir(), s.Config.Name), nil
+}
+func (s *sysvService) ConfigPath() string {
+	return filepath.Join(sysvInitDir, s.Name)
+}
+
+func (s *sysvService) renderScript() ([]byte, error) {
+	path, err := s.ExecPath()
+	if err != nil {
+		return nil, err
+	}
+	deps, err := lsbHeaders(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	if s.Config.ChRoot != "" {
+		return nil, fmt.Errorf("%s: SysV init scripts do not support ChRoot", s.Name)
+	}
+	for _, v := range []string{s.Config.Name, s.Config.DisplayName, s.Config.Description} {
+		if hasControl(v) {
+			return nil, fmt.Errorf("invalid control character in %q", v)
+		}
+	}
+
+	// The daemon runs through sh -c, which the shell fallback and
+	// start-stop-daemon share, so its output can be redirected.
+	words := []string{"exec", shellQuote(path)}
+	for _, a := range s.Config.Arguments {
+		words = append(words, shellQuote(a))
+	}
+	words = append(words,
+		">>"+shellQuote("/var/log/"+s.Name+".log"),
+		"2>>"+shellQuote("/var/log/"+s.Name+".err"),
+		"</dev/null")
+	// Only start-stop-daemon can switch to a group besides the user's own;
+	// the su fallback cannot.
+	user := s.user()
+	if _, err := sysvLookPath("start-stop-daemon"); err != nil && strings.Contains(user, ":") {
+		return nil, fmt.Errorf("%s: running as %s needs start-stop-daemon", s.Name, user)
+	}
+	envFile, err := s.environmentPath()
+	if err != nil {
+		return nil, err
+	}
+	data := &sysvData{
+		Config:          s.Config,
+		Deps:            deps,
+		User:            user,
+		Daemon:          strings.Join(words, " "),
+		EnvironmentFile: envFile,
+	}
+
+	return renderTemplate(s.Config.Option.String(service.OptionSysvScript, sysvScript), sysvFuncs, data)
+}
+
+var sysvFuncs = template.FuncMap{"shell": shellQuote}
+
+// IsSysV reports whether init scripts can be installed and enabled.
+func IsSysV() bool {
+	if info, err := os.Stat(sysvInitDir); err != nil || !info.IsDir() {
+		return false
+	}
+	for _, tool := range []string{"update-rc.d", "chkconfig"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+const sysvScript = `#!/bin/sh
+### BEGIN INIT INFO
+# Provides:          {{.Name}}
+# Required-Start:    {{.Deps.RequiredStart}}
+# Required-Stop:     {{.Deps.RequiredStop}}
+{{- if .Deps.ShouldStart}}
+# Should-Start:      {{.Deps.ShouldStart}}
+# Should-Stop:       {{.Deps.ShouldStop}}
+{{- end}}
+{{- if .Deps.StartBefore}}
+# X-Start-Before:    {{.Deps.StartBefore}}
+{{- end}}
+# Default-Start:     2 3 4 5
+# Default-Stop:      0 1 6
+# Short-Description: {{or .DisplayName .Name}}
+{{- if .Description}}
+# Description:       {{.Description}}
+{{- end}}
+### END INIT INFO
+
+# {{.Name}}: managed by keepgo, changes are overwritten.
+
+name={{shell .Name}}
+pidfile="/var/run/$name.pid"
+stdout_log="/var/log/$name.log"
+stderr_log="/var/log/$name.err"
+user={{shell .User}}
+chdir={{shell (or .WorkingDirectory "/")}}
+daemon={{shell .Daemon}}
+{{- if .EnvironmentFile}}
+
+if [ -r {{shell .EnvironmentFile}} ]; then
+	set -a
+	. {{shell .EnvironmentFile}}
+	set +a
+fi
+{{- end}}
+
+is_running() {
+	[ -f "$pidfile" ] && kill -0 "$(cat "$pidfile")" 2>/dev/null
+}
+
+do_start() {
+	is_running && return 0
+	touch "$stdout_log" "$stderr_log"
+	[ -n "$user" ] && chown "$user" "$stdout_log" "$stderr_log"
+	if command -v start-stop-daemon >/dev/null 2>&1; then
+		start-stop-daemon --start --quiet --background --make-pidfile --pidfile "$pidfile" \
+			${user:+--chuid "$user"} --chdir "$chdir" --startas /bin/sh -- -c "$daemon"
+	elif [ -n "$user" ]; then
+		case "$user" in
+		*:*)
+			echo "$name: start-stop-daemon is needed to run as $user" >&2
+			return 1
+			;;
+		esac
+		(cd "$chdir" && su -s /bin/sh -c "$daemon & echo \$!" "$user") > "$pidfile"
+	else
+		(cd "$chdir" && { /bin/sh -c "$daemon" & echo $! > "$pidfile"; })
+	fi
+}
+
+do_stop() {
+	if ! is_running; then
+		rm -f "$pidfile"
+		return 0
+	fi
+	if command -v start-stop-daemon >/dev/null 2>&1; then
+		start-stop-daemon --stop --quiet --retry=TERM/30/KILL/5 --pidfile "$pidfile"
+	else
+		kill "$(cat "$pidfile")"
+		i=0
+		while is_running && [ $i -lt 30 ]; do
+			sleep 1
+			i=$((i + 1))
+		done
+		is_running && kill -9 "$(cat "$pidfile")"
+	fi
+	rm -f "$pidfile"
+}
+
+do_status() {
+	if is_running; then
+		echo "$name is running"
+		return 0
+	fi
+	if [ -f "$pidfile" ]; then
+		echo "$name is dead but the pid file exists"
+		return 1
+	fi
+	echo "$name is not running"
+	return 3
+}
+
+case "$1" in
+start)
+	do_start
+	;;
+stop)
+	do_stop
+	;;
+restart|force-reload)
+	do_stop && do_start
+	;;
+status)
+	do_status
+	;;
+*)
+	echo "Usage: $0 {start|stop|restart|force-reload|status}" >&2
+	exit 2
+	;;
+esac
+exit $?
+`
@@ -0,0 +1,128 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestSysVService(t *testing.T, c *service.Config, tools ...string) (*sysvService, *fakeRunner) {
+	dir := t.TempDir()
+	setTestVar(t, &sysvInitDir, dir)
+	setTestVar(t, &sysconfigDir, filepath.Join(dir, "sysconfig"))
+	setTestVar(t, &etcDefaultDir, filepath.Join(dir, "default"))
+	setTestLookPath(t, &sysvLookPath, tools...)
+	svc, r := newTestInitService(t, c, "linux-sysv", NewSysVService)
+	return svc.(*sysvService), r
+}
+
+func TestSysVScript(t *testing.T) {
+	s, _ := newTestSysVService(t, &service.Config{
+		Name:          "app",
+		DisplayName:   "App",
+		Description:   "The app daemon",
+		UserName:      "app",
+		Arguments:     []string{"--greeting", "it's $HOME"},
+		SecretEnvVars: map[string]string{"TOKEN": "s3cret"},
+		Dependencies:  &service.Dependencies{Requires: []string{"postgresql"}, Wants: []string{"redis"}},
+	}, "update-rc.d")
+	script, err := s.renderScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"### BEGIN INIT INFO\n# Provides:          app\n",
+		"# Required-Start:    $remote_fs $syslog postgresql\n",
+		"# Should-Start:      redis\n",
+		"# Default-Start:     2 3 4 5\n# Default-Stop:      0 1 6\n",
+		"# Short-Description: App\n# Description:       The app daemon\n### END INIT INFO\n",
+		"user='app'\n",
+		"\t. '" + filepath.Join(etcDefaultDir, "app") + "'\n",
+		"start-stop-daemon --start",
+	} {
+		if !strings.Contains(string(script), want) {
+			t.Errorf("Expected %q in script:\n%s", want, script)
+		}
+	}
+	if strings.Contains(string(script), "s3cret") {
+		t.Errorf("Secret leaked into the init script:\n%s", script)
+	}
+
+	// The daemon command survives the sh -c it is run through.
+	daemon := `exec '/usr/bin/app' '--greeting' 'it'\''s $HOME' >>'/var/log/app.log' 2>>'/var/log/app.err' </dev/null`
+	if !strings.Contains(string(script), "daemon="+shellQuote(daemon)+"\n") {
+		t.Errorf("Expected daemon=%s in script:\n%s", shellQuote(daemon), script)
+	}
+
+	if _, err := exec.LookPath("sh"); err == nil {
+		out, err := exec.Command("sh", "-n", "-c", string(script)).CombinedOutput()
+		if err != nil {
+			t.Errorf("Script does not parse: %v\n%s", err, out)
+		}
+	}
+
+	s.Config.ChRoot = "/srv/jail"
+	if _, err := s.renderScript(); err == nil {
+		t.Error("Expected ChRoot to be rejected")
+	}
+	s.Config.ChRoot = ""
+
+	// Without start-stop-daemon the group would be lost.
+	s.Config.Option = service.KeyValue{service.OptionGroup: "staff"}
+	if _, err := s.renderScript(); err == nil || !strings.Contains(err.Error(), "start-stop-daemon") {
+		t.Errorf("Expected a group to need start-stop-daemon, got %v", err)
+	}
+	setTestLookPath(t, &sysvLookPath, "update-rc.d", "start-stop-daemon")
+	script, err = s.renderScript()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(script), "user='app:staff'\n") {
+		t.Errorf("Expected user='app:staff' in script:\n%s", script)
+	}
+}
+
+func TestSysVInstall(t *testing.T) {
+	tests := []struct {
+		tool  string
+		calls string
+	}{
+		{"update-rc.d", "update-rc.d app defaults|update-rc.d -f app remove"},
+		{"chkconfig", "chkconfig --add app|chkconfig --del app"},
+	}
+	for _, tt := range tests {
+		s, r := newTestSysVService(t, &service.Config{Name: "app", EnvVars: map[string]string{"PORT": "8080"}}, tt.tool)
+		if err := s.Install(); err != nil {
+			t.Fatal(err)
+		}
+		if info, err := os.Stat(s.ConfigPath()); err != nil || info.Mode().Perm() != 0755 {
+			t.Fatalf("Expected an executable init script, got %v, %v", info, err)
+		}
+		env, _ := s.EnvironmentFile()
+		if _, err := os.Stat(env); err != nil {
+			t.Error(err)
+		}
+		if err := s.Uninstall(); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(r.calls, "|"); got != tt.calls {
+			t.Errorf("Expected calls %q, got %q", tt.calls, got)
+		}
+		for _, path := range []string{s.ConfigPath(), env} {
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be removed, got %v", path, err)
+			}
+		}
+	}
+
+	s, _ := newTestSysVService(t, &service.Config{Name: "app"})
+	if err := s.Install(); err == nil {
+		t.Error("Expected install to fail without update-rc.d or chkconfig")
+	}
+	if _, err := os.Stat(s.ConfigPath()); !os.IsNotExist(err) {
+		t.Errorf("Expected no script to be written, got %v", err)
+	}
+}
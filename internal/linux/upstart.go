@@ -1,34 +1,77 @@
 package linux
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/faelmori/keepgo/runners"
 	"github.com/faelmori/keepgo/service"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
 	"time"
 )
 
+// upstartConfigDir is where upstart reads job configurations from.
+var upstartConfigDir = "/etc/init"
+
 func NewUpstartService(i service.Controller, platform string, c *service.Config, r *runners.Runner) (service.Service, error) {
-	return &upstartService{
-		Name: c.Name,
-	}, nil
+	s := &upstartService{}
+	// EnvVars are set with env stanzas, the file only holds secrets. The job
+	// script sources it after setuid, so it is owned by the service user.
+	s.initService = newInitService(i, platform, c, r, initEnv{path: s.environmentFile, secretsOnly: true, userOwned: true})
+	return s, nil
 }
 
 type upstartService struct {
-	Name string
+	initService
+}
+
+type upstartData struct {
+	*service.Config
+	Path            string
+	StartOn         string
+	StopOn          string
+	KeepAlive       bool
+	Group           string
+	LogOutput       bool
+	LogDirectory    string
+	EnvironmentFile string
 }
 
-func (s *upstartService) Run() error                                          { panic("implement me") }
-func (s *upstartService) Install() error                                      { panic("implement me") }
-func (s *upstartService) Uninstall() error                                    { panic("implement me") }
-func (s *upstartService) GetLogger(errs chan<- error) (service.Logger, error) { panic("implement me") }
-func (s *upstartService) SystemLogger(errs chan<- error) (service.Logger, error) {
-	panic("implement me")
+func (s *upstartService) Run() error { return s.runService(s) }
+func (s *upstartService) Install() error {
+	conf, err := s.renderConf()
+	if err != nil {
+		return err
+	}
+	return s.install(s.ConfigPath(), conf, 0644, func() error {
+		return s.runCommand("initctl", "reload-configuration")
+	})
 }
-func (s *upstartService) String() string                  { return s.Name }
-func (s *upstartService) Platform() string                { return "upstart" }
-func (s *upstartService) Status() (service.Status, error) { panic("implement me") }
-func (s *upstartService) Start() error                    { return runUpstartCommand("/sbin/start", s.Name) }
-func (s *upstartService) Stop() error                     { return runUpstartCommand("/sbin/stop", s.Name) }
+func (s *upstartService) Uninstall() error {
+	err := s.removeFiles(s.ConfigPath())
+	if err != nil {
+		return err
+	}
+	return s.runCommand("initctl", "reload-configuration")
+}
+func (s *upstartService) Platform() string { return "upstart" }
+func (s *upstartService) Status() (service.Status, error) {
+	_, out, err := s.RunWithOutput("initctl", "status", s.Name)
+	if err != nil {
+		var exitErr *runners.ExitError
+		if errors.As(err, &exitErr) && strings.Contains(exitErr.Stderr, "Unknown job") {
+			return service.StatusUnknown, service.ErrNotInstalled
+		}
+		return service.StatusUnknown, err
+	}
+	return parseUpstartStatus(out)
+}
+func (s *upstartService) Start() error { return s.runCommand("initctl", "start", s.Name) }
+func (s *upstartService) Stop() error  { return s.runCommand("initctl", "stop", s.Name) }
 func (s *upstartService) Restart() error {
 	err := s.Stop()
 	if err != nil {
@@ -38,12 +81,143 @@ func (s *upstartService) Restart() error {
 	return s.Start()
 }
 
-func runUpstartCommand(command string, arguments ...string) error {
-	cmd := exec.Command(command, arguments...)
-	return cmd.Run()
+// Logs implements service.LogReader, following the job's console log or,
+// with OptionLogOutput, the files in LogDirectory.
+func (s *upstartService) Logs(ctx context.Context, opts service.LogOptions) (service.LogStream, error) {
+	if s.Config.Option.Bool(service.OptionLogOutput, service.OptionLogOutputDefault) {
+		dir := s.Config.Option.String(service.OptionLogDirectory, service.OptionLogDirectoryDefault)
+		return fileLogs(ctx, []logFile{
+			{filepath.Join(dir, s.Name+".out"), service.PriorityInfo},
+			{filepath.Join(dir, s.Name+".err"), service.PriorityErr},
+		}, opts), nil
+	}
+	return fileLogs(ctx, []logFile{{"/var/log/upstart/" + s.Name + ".log", service.PriorityInfo}}, opts), nil
+}
+
+// Render returns the job configuration and commands a fresh Install would
+// produce. Secret environment values are masked.
+func (s *upstartService) Render() (*service.Rendering, error) {
+	conf, err := s.renderConf()
+	if err != nil {
+		return nil, err
+	}
+	return s.render(s.ConfigPath(), conf, 0644, []string{"initctl", "reload-configuration"})
+}
+
+// environmentFile is the file the job script sources secrets from.
+func (s *upstartService) environmentFile() (string, error) {
+	return filepath.Join(envFileDir(), s.Config.Name), nil
+}
+func (s *upstartService) ConfigPath() string {
+	return filepath.Join(upstartConfigDir, s.Name+".conf")
+}
+
+func (s *upstartService) renderConf() ([]byte, error) {
+	path, err := s.ExecPath()
+	if err != nil {
+		return nil, err
+	}
+	startOn, stopOn, err := upstartEvents(s.Config.Dependencies)
+	if err != nil {
+		return nil, err
+	}
+	envFile, err := s.environmentPath()
+	if err != nil {
+		return nil, err
+	}
+	data := &upstartData{
+		Config:          s.Config,
+		Path:            path,
+		StartOn:         startOn,
+		StopOn:          stopOn,
+		KeepAlive:       s.Config.Option.Bool(service.OptionKeepAlive, service.OptionKeepAliveDefault),
+		Group:           s.Config.Option.String(service.OptionGroup, ""),
+		LogOutput:       s.Config.Option.Bool(service.OptionLogOutput, service.OptionLogOutputDefault),
+		LogDirectory:    s.Config.Option.String(service.OptionLogDirectory, service.OptionLogDirectoryDefault),
+		EnvironmentFile: envFile,
+	}
+	return renderTemplate(s.Config.Option.String(service.OptionUpstartScript, upstartScript), upstartFuncs, data)
+}
+
+// parseUpstartStatus reads the goal of the first line `initctl status`
+// prints, such as "app start/running, process 1234". A job on its way up
+// counts as running, one on its way down as stopped.
+func parseUpstartStatus(out string) (service.Status, error) {
+	fields := strings.Fields(out)
+	if len(fields) >= 2 {
+		goal, _, ok := strings.Cut(strings.TrimSuffix(fields[1], ","), "/")
+		switch {
+		case ok && goal == "start":
+			return service.StatusRunning, nil
+		case ok && goal == "stop":
+			return service.StatusStopped, nil
+		}
+	}
+	return service.StatusUnknown, fmt.Errorf("unexpected initctl status output: %q", strings.TrimSpace(out))
+}
+
+var upstartFuncs = template.FuncMap{
+	"word":  upstartWord,
+	"env":   upstartEnv,
+	"shell": shellQuote,
+}
+
+var upstartPlainWord = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// upstartWord makes v a single stanza argument, double quoting it unless it
+// is a plain word. Inside quotes upstart only unescapes \" and \\.
+func upstartWord(v string) (string, error) {
+	if hasControl(v) {
+		return "", fmt.Errorf("invalid control character in %q", v)
+	}
+	if upstartPlainWord.MatchString(v) {
+		return v, nil
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v) + `"`, nil
+}
+
+// upstartEnv renders the argument of an env stanza.
+func upstartEnv(k, v string) (string, error) {
+	if !envNamePattern.MatchString(k) {
+		return "", fmt.Errorf("invalid environment variable name %q", k)
+	}
+	return upstartWord(k + "=" + v)
 }
 
 func IsUpstart() bool {
 	_, err := exec.LookPath("initctl")
 	return err == nil
 }
+
+const upstartScript = `# {{.Name}}: managed by keepgo, changes are overwritten.
+{{with or .Description .DisplayName}}description {{word .}}
+{{end}}
+start on {{.StartOn}}
+stop on {{.StopOn}}
+{{if .KeepAlive}}
+respawn
+respawn limit 10 5
+{{end}}
+{{- if .UserName}}setuid {{word .UserName}}
+{{end}}
+{{- if .Group}}setgid {{word .Group}}
+{{end}}
+{{- if .ChRoot}}chroot {{word .ChRoot}}
+{{end}}
+{{- if .WorkingDirectory}}chdir {{word .WorkingDirectory}}
+{{end}}
+{{- range $k, $v := .EnvVars}}env {{env $k $v}}
+{{end}}
+console log
+
+script
+{{- if .EnvironmentFile}}
+	if [ -r {{shell .EnvironmentFile}} ]; then
+		set -a
+		. {{shell .EnvironmentFile}}
+		set +a
+	fi
+{{- end}}
+	exec {{shell .Path}}{{range .Arguments}} {{shell .}}{{end}}{{if .LogOutput}} >>{{shell (printf "%s/%s.out" .LogDirectory .Name)}} 2>>{{shell (printf "%s/%s.err" .LogDirectory .Name)}}{{end}}
+end script
+`
@@ -0,0 +1,98 @@
+package linux
+
+import (
+	"github.com/faelmori/keepgo/service"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func newTestUpstartService(t *testing.T, c *service.Config) (*upstartService, *fakeRunner) {
+	dir := t.TempDir()
+	setTestVar(t, &upstartConfigDir, dir)
+	setTestVar(t, &sysconfigDir, filepath.Join(dir, "sysconfig"))
+	setTestVar(t, &etcDefaultDir, filepath.Join(dir, "default"))
+	svc, r := newTestInitService(t, c, "linux-upstart", NewUpstartService)
+	return svc.(*upstartService), r
+}
+
+func TestUpstartConf(t *testing.T) {
+	s, _ := newTestUpstartService(t, &service.Config{
+		Name:             "app",
+		Description:      `The "app" daemon`,
+		UserName:         "app",
+		Arguments:        []string{"--listen", ":8080", "it's"},
+		WorkingDirectory: "/srv/my app",
+		EnvVars:          map[string]string{"PORT": "8080", "GREETING": "hello world"},
+		SecretEnvVars:    map[string]string{"TOKEN": "s3cret"},
+		Dependencies:     &service.Dependencies{Requires: []string{"postgresql"}},
+		Option:           service.KeyValue{service.OptionGroup: "staff"},
+	})
+	conf, err := s.renderConf()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := filepath.Join(etcDefaultDir, "app")
+	for _, want := range []string{
+		`description "The \"app\" daemon"` + "\n",
+		"start on (runlevel [2345] and started postgresql)\n",
+		"stop on runlevel [!2345]\n",
+		"respawn\nrespawn limit 10 5\n",
+		"setuid app\nsetgid staff\n",
+		`chdir "/srv/my app"` + "\n",
+		"env \"GREETING=hello world\"\nenv PORT=8080\n",
+		"\t. '" + env + "'\n",
+		`exec '/usr/bin/app' '--listen' ':8080' 'it'\''s'` + "\n",
+	} {
+		if !strings.Contains(string(conf), want) {
+			t.Errorf("Expected %q in conf:\n%s", want, conf)
+		}
+	}
+	if strings.Contains(string(conf), "s3cret") {
+		t.Errorf("Secret leaked into the world readable conf:\n%s", conf)
+	}
+
+	s.Config.EnvVars["BAD NAME"] = "x"
+	if _, err := s.renderConf(); err == nil {
+		t.Error("Expected an invalid variable name to fail rendering")
+	}
+}
+
+func TestUpstartSecretsReadableAfterSetuid(t *testing.T) {
+	u, err := user.Lookup("nobody")
+	if err != nil {
+		t.Skip("no nobody account")
+	}
+	s, _ := newTestUpstartService(t, &service.Config{
+		Name:          "app",
+		UserName:      "nobody",
+		SecretEnvVars: map[string]string{"TOKEN": "s3cret"},
+	})
+	if err := s.Install(); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := os.ReadFile(s.ConfigPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, _ := s.EnvironmentFile()
+	if !strings.Contains(string(conf), "setuid nobody\n") || !strings.Contains(string(conf), "\t. '"+env+"'\n") {
+		t.Errorf("Expected the job to source the secrets as nobody:\n%s", conf)
+	}
+	info, err := os.Stat(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	if os.Geteuid() == 0 {
+		if uid := strconv.Itoa(int(info.Sys().(*syscall.Stat_t).Uid)); uid != u.Uid {
+			t.Errorf("Expected the secrets owned by nobody (%s), got uid %s", u.Uid, uid)
+		}
+	}
+}
@@ -33,69 +33,35 @@ func (sc linuxSystemService) New(i service.Controller, c *service.Config) (servi
 	return sc.new(i, sc.String(), c, sc.runner)
 }
 
-func init() {
-	systems := make([]linuxSystemService, 0)
+// linuxSystems lists the init systems in the order they are registered.
+// ChooseSystem keeps the last one registered, so SysV, whose scripts and
+// tools linger on hosts run by systemd, upstart or OpenRC, comes first.
+var linuxSystems = []linuxSystemService{
+	{name: "linux-sysv", detect: lnx.IsSysV, interactive: isInteractive, new: lnx.NewSysVService},
+	{name: "linux-systemd", detect: lnx.IsSystemd, interactive: isInteractive, new: lnx.NewSystemdService},
+	{name: "linux-upstart", detect: lnx.IsUpstart, interactive: isInteractive, new: lnx.NewUpstartService},
+	{name: "linux-openrc", detect: lnx.IsOpenRC, interactive: isInteractive, new: lnx.NewOpenRCService},
+	{name: "linux-rcs", detect: lnx.IsRCS, interactive: isInteractive, new: lnx.NewRCSService},
+	{name: "unix", detect: lnx.IsUnix, interactive: isInteractive, new: lnx.NewUnixService},
+}
 
-	if lnx.IsSystemd() {
-		systems = append(systems, linuxSystemService{
-			name:   "linux-systemd",
-			detect: lnx.IsSystemd,
-			interactive: func() bool {
-				is, _ := IsInteractive()
-				return is
-			},
-			new: lnx.NewSystemdService,
-		})
-	}
-	if lnx.IsUpstart() {
-		systems = append(systems, linuxSystemService{
-			name:   "linux-upstart",
-			detect: lnx.IsUpstart,
-			interactive: func() bool {
-				is, _ := IsInteractive()
-				return is
-			},
-			new: lnx.NewUpstartService,
-		})
-	}
-	if lnx.IsOpenRC() {
-		systems = append(systems, linuxSystemService{
-			name:   "linux-openrc",
-			detect: lnx.IsOpenRC,
-			interactive: func() bool {
-				is, _ := IsInteractive()
-				return is
-			},
-			new: lnx.NewOpenRCService,
-		})
-	}
-	if lnx.IsRCS() {
-		systems = append(systems, linuxSystemService{
-			name:   "linux-rcs",
-			detect: lnx.IsRCS,
-			interactive: func() bool {
-				is, _ := IsInteractive()
-				return is
-			},
-			new: lnx.NewRCSService,
-		})
-	}
-	if lnx.IsUnix() {
-		systems = append(systems, linuxSystemService{
-			name:   "unix",
-			detect: lnx.IsUnix,
-			interactive: func() bool {
-				is, _ := IsInteractive()
-				return is
-			},
-			new: lnx.NewUnixService,
-		})
-	}
+func init() {
+	registerSystems(linuxSystems)
+}
 
+// registerSystems registers those of systems detected on the host, in
+// order, so the last of them is chosen.
+func registerSystems(systems []linuxSystemService) {
 	for _, s := range systems {
-		service.ChooseSystem(s)
+		if s.detect() {
+			service.ChooseSystem(s)
+		}
 	}
 }
+func isInteractive() bool {
+	is, _ := IsInteractive()
+	return is
+}
 func BinaryName(pid int) (string, error) {
 	statPath := fmt.Sprintf("/proc/%d/stat", pid)
 	dataBytes, err := os.ReadFile(statPath)
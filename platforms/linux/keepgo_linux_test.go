@@ -4,6 +4,7 @@ import (
 	lnx "github.com/faelmori/keepgo/internal/linux"
 	"github.com/faelmori/keepgo/runners"
 	"github.com/faelmori/keepgo/service"
+	"strings"
 	"testing"
 )
 
@@ -22,8 +23,8 @@ func (s *systemdService) Start() error {
 func TestSystemdServiceStatus(t *testing.T) {
 	config := &service.Config{Name: "test-service"}
 	ctrl := &mockController{}
-	//runner := &RunnerImpl{}
-	svc, err := lnx.NewSystemdService(ctrl, "linux-systemd", config, nil)
+	var runner runners.Runner = &RunnerImpl{}
+	svc, err := lnx.NewSystemdService(ctrl, "linux-systemd", config, &runner)
 	if err != nil {
 		t.Fatalf("Failed to create systemd service: %v", err)
 	}
@@ -37,14 +38,112 @@ func TestSystemdServiceStatus(t *testing.T) {
 	}
 }
 
+func TestSystemdServiceUsesRunner(t *testing.T) {
+	config := &service.Config{Name: "test-service"}
+	runner := &RunnerImpl{}
+	var r runners.Runner = runner
+	svc, err := lnx.NewSystemdService(&mockController{}, "linux-systemd", config, &r)
+	if err != nil {
+		t.Fatalf("Failed to create systemd service: %v", err)
+	}
+
+	if err := svc.Stop(); err != nil {
+		t.Fatalf("Failed to stop service: %v", err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("Expected 1 runner call, got %d", len(runner.calls))
+	}
+	want := []string{"systemctl", "stop", "test-service.service"}
+	if strings.Join(runner.calls[0], " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v, got %v", want, runner.calls[0])
+	}
+}
+
+type failingRunner struct{}
+
+func (failingRunner) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	return 3, "inactive\n", &runners.ExitError{Command: command, Args: arguments, ExitCode: 3}
+}
+
+func TestSystemdServiceStatusNotInstalled(t *testing.T) {
+	var r runners.Runner = failingRunner{}
+	svc, err := lnx.NewSystemdService(&mockController{}, "linux-systemd", &service.Config{Name: "test-service"}, &r)
+	if err != nil {
+		t.Fatalf("Failed to create systemd service: %v", err)
+	}
+	if _, err := svc.Status(); err != service.ErrNotInstalled {
+		t.Errorf("Expected %v, got %v", service.ErrNotInstalled, err)
+	}
+}
+
+func TestRegisterSystemsSysVLowestPriority(t *testing.T) {
+	defer service.ChooseSystem(service.AvailableSystems()...)
+	for _, name := range []string{"linux-systemd", "linux-upstart", "linux-openrc"} {
+		systems := make([]linuxSystemService, len(linuxSystems))
+		copy(systems, linuxSystems)
+		for i := range systems {
+			detected := systems[i].name == name || systems[i].name == "linux-sysv"
+			systems[i].detect = func() bool { return detected }
+		}
+		registerSystems(systems)
+		if got := service.ChosenSystem(); got == nil || got.String() != name {
+			t.Errorf("Expected %s to win over linux-sysv, got %v", name, got)
+		}
+	}
+}
+
 type mockController struct{}
 
 func (m *mockController) Start(s service.Service) error { return nil }
 func (m *mockController) Stop(s service.Service) error  { return nil }
 func (m *mockController) Run() error                    { return nil }
 
-type RunnerImpl struct{}
+type RunnerImpl struct {
+	calls [][]string
+}
 
 func (r *RunnerImpl) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	r.calls = append(r.calls, append([]string{command}, arguments...))
+	if command == "systemctl" && len(arguments) > 0 && arguments[0] == "is-active" {
+		return 0, "active\n", nil
+	}
 	return 0, "", nil
 }
+
+type showRunner struct{ out string }
+
+func (r showRunner) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	return 0, r.out, nil
+}
+
+func TestSystemdServiceStatusDetail(t *testing.T) {
+	var r runners.Runner = showRunner{out: `ActiveState=activating
+SubState=auto-restart
+LoadState=loaded
+UnitFileState=enabled
+MainPID=0
+ExecMainStatus=1
+NRestarts=4
+ActiveEnterTimestamp=
+MemoryCurrent=[not set]
+CPUUsageNSec=18446744073709551615
+`}
+	svc, err := lnx.NewSystemdService(&mockController{}, "linux-systemd", &service.Config{Name: "test-service"}, &r)
+	if err != nil {
+		t.Fatalf("Failed to create systemd service: %v", err)
+	}
+
+	d, err := service.Detail(svc)
+	if err != nil {
+		t.Fatalf("Failed to get status detail: %v", err)
+	}
+	if d.Status != service.StatusRunning || d.ExecMainStatus != 1 || d.NRestarts != 4 {
+		t.Errorf("Unexpected detail: %+v", d)
+	}
+	if d.MemoryCurrent != 0 || d.CPUUsage != 0 {
+		t.Errorf("Expected unset accounting to be zero, got %d and %v", d.MemoryCurrent, d.CPUUsage)
+	}
+	if !d.CrashLooping() {
+		t.Errorf("Expected service to be reported as crash-looping")
+	}
+}
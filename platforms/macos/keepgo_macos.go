@@ -2,6 +2,7 @@ package macos
 
 import (
 	"fmt"
+	"github.com/faelmori/keepgo/service"
 	"os/exec"
 	"time"
 )
@@ -27,6 +28,12 @@ func (s *macosService) Restart() error {
 	return s.Start()
 }
 
+// Render is not supported until the launchd backend can install services
+// and so has a plist to preview.
+func (s *macosService) Render() (*service.Rendering, error) {
+	return nil, service.ErrNotSupported
+}
+
 func (s *macosService) getPlistPath() string {
 	return fmt.Sprintf("/Library/LaunchDaemons/%s.plist", s.Name)
 }
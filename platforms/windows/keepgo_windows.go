@@ -283,13 +283,18 @@ func (ws *windowsService) Install() error {
 		serviceType = serviceType | windows.SERVICE_INTERACTIVE_PROCESS
 	}
 
+	var dependencies []string
+	if ws.Dependencies != nil {
+		dependencies = append(append(dependencies, ws.Dependencies.Requires...), ws.Dependencies.BindsTo...)
+	}
+
 	s, err = m.CreateService(ws.Name, exepath, mgr.Config{
 		DisplayName:      ws.DisplayName,
 		Description:      ws.Description,
 		StartType:        uint32(startType),
 		ServiceStartName: ws.UserName,
 		Password:         ws.Option.string("Password", ""),
-		Dependencies:     ws.Dependencies,
+		Dependencies:     dependencies,
 		DelayedAutoStart: ws.Option.bool("DelayedAutoStart", false),
 		ServiceType:      uint32(serviceType),
 	}, ws.Arguments...)
@@ -512,3 +517,9 @@ func (ws *windowsService) SystemLogger(errs chan<- error) (Logger, error) {
 	}
 	return WindowsLogger{el, errs}, nil
 }
+
+// Render is not supported: Install registers the service with the service
+// control manager and writes the registry, it produces no files to preview.
+func (ws *windowsService) Render() (*Rendering, error) {
+	return nil, ErrNotSupported
+}
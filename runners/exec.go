@@ -0,0 +1,99 @@
+package runners
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Result holds everything a finished command produced.
+type Result struct {
+	ExitCode int
+	Stdout   string
+	Stderr   string
+}
+
+// ExitError is returned when a command ran but exited with a non-zero code.
+type ExitError struct {
+	Command  string
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExitError) Error() string {
+	cmdLine := strings.TrimSpace(e.Command + " " + strings.Join(e.Args, " "))
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		return fmt.Sprintf("%q exited with code %d", cmdLine, e.ExitCode)
+	}
+	return fmt.Sprintf("%q exited with code %d: %s", cmdLine, e.ExitCode, msg)
+}
+
+// IsExitError reports whether err means the command ran and failed, as
+// opposed to not being runnable at all.
+func IsExitError(err error) bool {
+	var exitErr *ExitError
+	return errors.As(err, &exitErr)
+}
+
+// ExecRunner runs commands on the local host through os/exec.
+type ExecRunner struct {
+	Env []string // Extra environment, appended to the current one.
+	Dir string   // Working directory, current one when empty.
+}
+
+func NewExecRunner() *ExecRunner { return &ExecRunner{} }
+
+// WithEnv returns a copy of r that adds env to the environment of every
+// command, leaving r itself unchanged.
+func (r *ExecRunner) WithEnv(env ...string) *ExecRunner {
+	c := *r
+	c.Env = append(append([]string(nil), r.Env...), env...)
+	return &c
+}
+
+// Run executes command and captures its exit code, stdout and stderr
+// separately. A non-zero exit yields a *ExitError alongside the result;
+// any other error means the command could not be started and ExitCode is -1.
+func (r *ExecRunner) Run(command string, arguments ...string) (Result, error) {
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.Command(command, arguments...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(cmd.Environ(), r.Env...)
+	}
+
+	err := cmd.Run()
+	res := Result{ExitCode: 0, Stdout: stdout.String(), Stderr: stderr.String()}
+	if err == nil {
+		return res, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		res.ExitCode = exitErr.ExitCode()
+		return res, &ExitError{Command: command, Args: arguments, ExitCode: res.ExitCode, Stderr: res.Stderr}
+	}
+	res.ExitCode = -1
+	return res, err
+}
+
+// RunWithOutput implements Runner, returning only stdout.
+func (r *ExecRunner) RunWithOutput(command string, arguments ...string) (int, string, error) {
+	res, err := r.Run(command, arguments...)
+	return res.ExitCode, res.Stdout, err
+}
+
+// Resolve returns the runner r points to, or a fresh ExecRunner when there is none.
+func Resolve(r *Runner) Runner {
+	if r == nil || *r == nil {
+		return NewExecRunner()
+	}
+	return *r
+}
@@ -0,0 +1,67 @@
+package runners
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestExecRunnerSeparatesStreams(t *testing.T) {
+	res, err := NewExecRunner().Run("sh", "-c", "echo out; echo err >&2; exit 3")
+	if !IsExitError(err) {
+		t.Fatalf("Expected an exit error, got %v", err)
+	}
+	if res.ExitCode != 3 {
+		t.Errorf("Expected exit code 3, got %d", res.ExitCode)
+	}
+	if res.Stdout != "out\n" || res.Stderr != "err\n" {
+		t.Errorf("Unexpected output: stdout=%q stderr=%q", res.Stdout, res.Stderr)
+	}
+}
+
+func TestExecRunnerMissingCommand(t *testing.T) {
+	code, _, err := NewExecRunner().RunWithOutput("keepgo-no-such-command")
+	if err == nil || IsExitError(err) {
+		t.Fatalf("Expected a start error, got %v", err)
+	}
+	if code != -1 {
+		t.Errorf("Expected exit code -1, got %d", code)
+	}
+}
+
+func TestExecRunnerWithEnv(t *testing.T) {
+	base := NewExecRunner()
+	r := base.WithEnv("KEEPGO_TEST=yes")
+	_, out, err := r.RunWithOutput("sh", "-c", "echo $KEEPGO_TEST")
+	if err != nil || out != "yes\n" {
+		t.Errorf("Expected the extra variable in the command environment, got %q, %v", out, err)
+	}
+	if len(base.Env) != 0 {
+		t.Errorf("Expected the original runner to be left alone, got %v", base.Env)
+	}
+}
+
+func TestExecRunnerStream(t *testing.T) {
+	stream, err := NewExecRunner().Stream(context.Background(), "sh", "-c", "echo one; echo two; echo bad >&2; exit 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	out, err := io.ReadAll(stream)
+	if string(out) != "one\ntwo\n" {
+		t.Errorf("Unexpected output %q", out)
+	}
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode != 2 || exitErr.Stderr != "bad\n" {
+		t.Errorf("Expected the exit error at the end of the stream, got %v", err)
+	}
+
+	stream, err = NewExecRunner().Stream(context.Background(), "sleep", "60")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("Expected closing a running command to succeed, got %v", err)
+	}
+}
@@ -1,5 +1,9 @@
 package runners
 
+// Runner executes external commands on behalf of the service backends.
+// RunWithOutput returns the exit code and stdout of the command; a non-zero
+// exit is reported as a *ExitError so callers can tell it apart from a
+// command that could not be run at all.
 type Runner interface {
 	RunWithOutput(command string, arguments ...string) (int, string, error)
 }
@@ -0,0 +1,82 @@
+package runners
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// StreamRunner is implemented by runners able to hand out the output of a
+// long running command while it is produced, e.g. journalctl --follow.
+type StreamRunner interface {
+	Runner
+	// Stream starts command and returns its stdout. Reads end with a
+	// *ExitError instead of io.EOF when the command fails. Closing the
+	// reader or cancelling ctx kills the command.
+	Stream(ctx context.Context, command string, arguments ...string) (io.ReadCloser, error)
+}
+
+// Stream implements StreamRunner.
+func (r *ExecRunner) Stream(ctx context.Context, command string, arguments ...string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, command, arguments...)
+	cmd.Dir = r.Dir
+	if len(r.Env) > 0 {
+		cmd.Env = append(cmd.Environ(), r.Env...)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	s := &execStream{cmd: cmd, stdout: stdout, command: command, args: arguments}
+	cmd.Stderr = &s.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type execStream struct {
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+	stderr  bytes.Buffer
+	command string
+	args    []string
+
+	once    sync.Once
+	waitErr error
+}
+
+func (s *execStream) Read(p []byte) (int, error) {
+	n, err := s.stdout.Read(p)
+	if err == io.EOF {
+		if werr := s.wait(); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// Close kills the command if it is still running. Having been killed is not
+// reported as an error.
+func (s *execStream) Close() error {
+	s.once.Do(func() {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	})
+	return nil
+}
+
+func (s *execStream) wait() error {
+	s.once.Do(func() {
+		err := s.cmd.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			err = &ExitError{Command: s.command, Args: s.args, ExitCode: exitErr.ExitCode(), Stderr: s.stderr.String()}
+		}
+		s.waitErr = err
+	})
+	return s.waitErr
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Credential is a secret the service manager passes to the service as a
+// file in $CREDENTIALS_DIRECTORY instead of through its environment, see
+// systemd.exec(5). Set exactly one of Path and Value.
+type Credential struct {
+	Name      string // Name the service reads the credential by, see ReadCredential.
+	Path      string // File or AF_UNIX socket the service manager reads the credential from.
+	Value     string // Literal value. Ends up in the unit file, prefer Path or Encrypted for real secrets.
+	Encrypted bool   // Path or Value holds a credential encrypted with systemd-creds.
+}
+
+// Validate checks the credential can be rendered.
+func (c Credential) Validate() error {
+	if c.Name == "" || strings.ContainsAny(c.Name, "/:") || c.Name == "." || c.Name == ".." {
+		return fmt.Errorf("invalid credential name %q", c.Name)
+	}
+	if (c.Path == "") == (c.Value == "") {
+		return fmt.Errorf("credential %s: set exactly one of Path and Value", c.Name)
+	}
+	return nil
+}
+
+// ReadCredential returns the credential name passed in by the service
+// manager. When the process was not started with credentials, it reads
+// fallback instead, e.g. a file in a development checkout; an empty
+// fallback yields an error wrapping os.ErrNotExist.
+func ReadCredential(name, fallback string) ([]byte, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, fmt.Errorf("invalid credential name %q", name)
+	}
+	if dir := os.Getenv("CREDENTIALS_DIRECTORY"); dir != "" {
+		return os.ReadFile(filepath.Join(dir, name))
+	}
+	if fallback == "" {
+		return nil, fmt.Errorf("credential %s: not running with credentials and no fallback: %w", name, os.ErrNotExist)
+	}
+	return os.ReadFile(fallback)
+}
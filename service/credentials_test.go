@@ -0,0 +1,38 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadCredential(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db"), []byte("from systemd"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	fallback := filepath.Join(t.TempDir(), "db.secret")
+	if err := os.WriteFile(fallback, []byte("from disk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CREDENTIALS_DIRECTORY", dir)
+	if data, err := ReadCredential("db", fallback); err != nil || string(data) != "from systemd" {
+		t.Errorf("Expected the passed credential, got %q, %v", data, err)
+	}
+	if _, err := ReadCredential("missing", fallback); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing credential not to fall back, got %v", err)
+	}
+
+	t.Setenv("CREDENTIALS_DIRECTORY", "")
+	if data, err := ReadCredential("db", fallback); err != nil || string(data) != "from disk" {
+		t.Errorf("Expected the fallback, got %q, %v", data, err)
+	}
+	if _, err := ReadCredential("db", ""); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected os.ErrNotExist without a fallback, got %v", err)
+	}
+	if _, err := ReadCredential("../db", fallback); err == nil {
+		t.Error("Expected a name with a slash to be rejected")
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dependencies relates the service to other services. Names are service
+// names as the backend knows them; systemd units without a suffix are taken
+// to be services. Backends translate what their init system can express and
+// ignore the rest.
+type Dependencies struct {
+	After         []string // Start after these when both are being started.
+	Before        []string // Start before these when both are being started.
+	Requires      []string // Start these too and fail when they fail.
+	Wants         []string // Start these too but carry on when they fail.
+	BindsTo       []string // Like Requires, and stop when any of them stops.
+	PartOf        []string // Stop and restart along with these.
+	Conflicts     []string // Never run at the same time as these.
+	NetworkOnline bool     // Start once the network is configured, not just up.
+}
+
+// Validate checks every name can be written into a unit or script.
+func (d *Dependencies) Validate() error {
+	for _, list := range []struct {
+		name  string
+		names []string
+	}{
+		{"After", d.After}, {"Before", d.Before}, {"Requires", d.Requires}, {"Wants", d.Wants},
+		{"BindsTo", d.BindsTo}, {"PartOf", d.PartOf}, {"Conflicts", d.Conflicts},
+	} {
+		for _, n := range list.names {
+			if n == "" || strings.ContainsAny(n, " \t\r\n\"'\\/;$`") {
+				return fmt.Errorf("%s: invalid service name %q", list.name, n)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package service
+
+import "fmt"
+
+const (
+	HardeningNone           = "none"
+	HardeningNetworkService = "network-service"
+	HardeningStrict         = "strict"
+)
+
+// Hardening holds sandboxing settings for the service. Zero values leave the
+// service manager's defaults alone. A nil slice is not rendered while an
+// empty, non-nil one clears the setting, e.g. CapabilityBoundingSet drops
+// every capability.
+type Hardening struct {
+	NoNewPrivileges         bool
+	ProtectSystem           string // true, full or strict.
+	ProtectHome             string // true, read-only or tmpfs.
+	PrivateTmp              bool
+	PrivateDevices          bool
+	ReadWritePaths          []string
+	CapabilityBoundingSet   []string // e.g. CAP_NET_BIND_SERVICE.
+	AmbientCapabilities     []string
+	RestrictAddressFamilies []string // e.g. AF_INET, AF_INET6, AF_UNIX.
+	SystemCallFilter        []string // e.g. @system-service.
+}
+
+// HardeningPreset returns the named set of hardening settings: "none",
+// "network-service" for daemons serving the network, or "strict" for
+// services needing neither network nor write access outside ReadWritePaths.
+func HardeningPreset(name string) (*Hardening, error) {
+	switch name {
+	case HardeningNone, "":
+		return &Hardening{}, nil
+	case HardeningNetworkService:
+		return &Hardening{
+			NoNewPrivileges:         true,
+			ProtectSystem:           "full",
+			ProtectHome:             "true",
+			PrivateTmp:              true,
+			PrivateDevices:          true,
+			RestrictAddressFamilies: []string{"AF_UNIX", "AF_INET", "AF_INET6"},
+			SystemCallFilter:        []string{"@system-service"},
+		}, nil
+	case HardeningStrict:
+		return &Hardening{
+			NoNewPrivileges:         true,
+			ProtectSystem:           "strict",
+			ProtectHome:             "true",
+			PrivateTmp:              true,
+			PrivateDevices:          true,
+			CapabilityBoundingSet:   []string{},
+			RestrictAddressFamilies: []string{"AF_UNIX"},
+			SystemCallFilter:        []string{"@system-service"},
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown hardening preset %q", name)
+}
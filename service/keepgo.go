@@ -19,6 +19,8 @@ var (
 	ErrNoServiceSystemDetected = errors.New("No service SystemVar detected.")
 	// ErrNotInstalled is returned when the service is not installed.
 	ErrNotInstalled = errors.New("the service is not installed")
+	// ErrNotSupported is returned when the backend lacks an optional capability.
+	ErrNotSupported = errors.New("not supported by this service system")
 	ControlAction   = [5]string{"start", "stop", "restart", "install", "uninstall"}
 )
 
@@ -30,11 +32,17 @@ type Config struct {
 	UserName         string   // Run as username.
 	Arguments        []string // Run with arguments.
 	Executable       string
-	Dependencies     []string
-	WorkingDirectory string // Initial working directory.
+	Dependencies     *Dependencies // Ordering and requirements on other services.
+	WorkingDirectory string        // Initial working directory.
 	ChRoot           string
 	Option           KeyValue
 	EnvVars          map[string]string
+	SecretEnvVars    map[string]string // Like EnvVars, but only ever written to the root-only environment file.
+	Credentials      []Credential      // Secrets passed as files, see ReadCredential.
+	Listen           []ListenAddress   // Sockets the service manager opens for the service.
+	Schedule         *Schedule         // Run as a scheduled job instead of a daemon.
+	Hardening        *Hardening        // Sandboxing, see HardeningPreset. Overrides OptionHardening.
+	Resources        *Resources        // Memory, CPU and rlimit caps.
 }
 type KeyValue map[string]interface{}
 type System interface {
@@ -72,6 +80,46 @@ type Service interface {
 	Platform() string
 	Status() (Status, error)
 }
+
+// InstanceManager is implemented by services installed once as a template
+// and run as several named instances side by side.
+type InstanceManager interface {
+	Instance(name string) (Service, error)
+	Instances() ([]string, error)
+}
+
+// DropInManager is implemented by services whose definition can be amended
+// with override files, such as systemd drop-ins, without reinstalling.
+type DropInManager interface {
+	SetDropIn(name string, content []byte) error
+	DropIns() ([]string, error)
+	DropIn(name string) ([]byte, error)
+	RemoveDropIn(name string) error
+}
+
+// InstallResult reports what an upgrade did.
+type InstallResult struct {
+	Changed   bool     // Anything was written.
+	Files     []string // Files created or rewritten.
+	Restarted bool     // The running service was restarted to apply the changes.
+}
+
+// Upgrader is implemented by services that can be installed over an
+// existing installation, rewriting only what differs.
+type Upgrader interface {
+	Upgrade(restart bool) (InstallResult, error)
+}
+
+// EnvironmentManager is implemented by services reading their environment
+// from a file only root (or the owning user) can read. WriteEnvironment
+// replaces the file with SecretEnvVars, and with EnvVars where the service
+// manager has no other place for them; the service picks the change up on
+// its next start.
+type EnvironmentManager interface {
+	EnvironmentFile() (string, error)
+	WriteEnvironment() error
+	RemoveEnvironment() error
+}
 type Logger interface {
 	Error(v ...interface{}) error
 	Warning(v ...interface{}) error
@@ -188,3 +236,15 @@ func Control(s Service, action string) error {
 	}
 	return nil
 }
+
+// JobError is returned when the service manager ran a job for a unit and the
+// job finished with a result other than success.
+type JobError struct {
+	Unit      string // Unit the job was queued for.
+	Operation string // What was asked of the unit, e.g. start or stop.
+	Result    string // Result reported by the service manager, e.g. failed or timeout.
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s %s: job finished with result %q", e.Operation, e.Unit, e.Result)
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// listenFdsStart is the first file descriptor passed by socket activation.
+const listenFdsStart = 3
+
+// ListenAddress describes a socket the service accepts connections or
+// datagrams on. Backends supporting socket activation create the socket on
+// behalf of the service so it survives restarts.
+type ListenAddress struct {
+	Network string // tcp, tcp4, tcp6, udp, udp4, udp6, unix, unixgram or unixpacket.
+	Address string // host:port, :port or a socket path.
+	Name    string // Optional name handed back in Socket.Name.
+}
+
+// Stream reports whether the address is connection oriented.
+func (l ListenAddress) Stream() bool {
+	switch l.Network {
+	case "udp", "udp4", "udp6", "unixgram":
+		return false
+	}
+	return true
+}
+
+// Socket is a listening socket, either inherited from the service manager or
+// bound by Listeners itself. Exactly one of Listener and PacketConn is set.
+type Socket struct {
+	Name       string
+	Listener   net.Listener
+	PacketConn net.PacketConn
+	Activated  bool // Passed in by the service manager.
+}
+
+// Listeners returns the sockets passed in through socket activation
+// (LISTEN_FDS, LISTEN_PID and LISTEN_FDNAMES). When the process was not
+// socket activated it binds the given addresses itself instead, so the
+// service works the same when started by hand.
+//
+// Inherited sockets arrive in the order the addresses were configured; when
+// their count matches, they are named after the given addresses.
+func Listeners(listen ...ListenAddress) ([]Socket, error) {
+	sockets, err := activatedSockets(listenFdsStart)
+	if err != nil {
+		return nil, err
+	}
+	if sockets != nil {
+		if len(sockets) == len(listen) {
+			for i := range sockets {
+				if listen[i].Name != "" {
+					sockets[i].Name = listen[i].Name
+				}
+			}
+		}
+		return sockets, nil
+	}
+
+	for _, l := range listen {
+		s := Socket{Name: l.Name}
+		if l.Stream() {
+			s.Listener, err = net.Listen(l.Network, l.Address)
+		} else {
+			s.PacketConn, err = net.ListenPacket(l.Network, l.Address)
+		}
+		if err != nil {
+			closeSockets(sockets)
+			return nil, err
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, nil
+}
+
+// activatedSockets wraps the inherited descriptors and clears the
+// activation environment so child processes do not pick them up again.
+// It returns nil when the process was not socket activated.
+func activatedSockets(start int) ([]Socket, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	sockets := make([]Socket, 0, n)
+	for i := 0; i < n; i++ {
+		s := Socket{Activated: true}
+		if i < len(names) {
+			s.Name = names[i]
+		}
+
+		f := os.NewFile(uintptr(start+i), s.Name)
+		s.Listener, err = net.FileListener(f)
+		if err != nil {
+			s.PacketConn, err = net.FilePacketConn(f)
+		}
+		f.Close()
+		if err != nil {
+			closeSockets(sockets)
+			return nil, fmt.Errorf("socket activation: fd %d: %v", start+i, err)
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets, nil
+}
+
+func closeSockets(sockets []Socket) {
+	for _, s := range sockets {
+		if s.Listener != nil {
+			s.Listener.Close()
+		}
+		if s.PacketConn != nil {
+			s.PacketConn.Close()
+		}
+	}
+}
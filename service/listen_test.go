@@ -0,0 +1,61 @@
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestListenersActivated(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "http")
+	sockets, err := activatedSockets(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSockets(sockets)
+
+	if len(sockets) != 1 || sockets[0].Listener == nil || !sockets[0].Activated {
+		t.Fatalf("Unexpected sockets %+v", sockets)
+	}
+	if sockets[0].Name != "http" {
+		t.Errorf("Expected name http, got %q", sockets[0].Name)
+	}
+	if sockets[0].Listener.Addr().String() != l.Addr().String() {
+		t.Errorf("Expected %s, got %s", l.Addr(), sockets[0].Listener.Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Errorf("Expected activation environment to be cleared")
+	}
+}
+
+func TestListenersFallback(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	sockets, err := Listeners(
+		ListenAddress{Network: "tcp", Address: "127.0.0.1:0", Name: "http"},
+		ListenAddress{Network: "udp", Address: "127.0.0.1:0", Name: "dns"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSockets(sockets)
+
+	if len(sockets) != 2 || sockets[0].Listener == nil || sockets[1].PacketConn == nil {
+		t.Fatalf("Unexpected sockets %+v", sockets)
+	}
+	if sockets[0].Activated || sockets[1].Name != "dns" {
+		t.Errorf("Unexpected sockets %+v", sockets)
+	}
+}
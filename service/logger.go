@@ -0,0 +1,61 @@
+package service
+
+import (
+	"log"
+	"os"
+)
+
+// FieldLogger is implemented by loggers able to attach structured fields to
+// their entries, such as the journald logger.
+type FieldLogger interface {
+	Logger
+	// WithFields returns a logger adding fields to every entry. Names follow
+	// the journal conventions: upper case letters, digits and underscores.
+	WithFields(fields map[string]string) Logger
+}
+
+// WithFields returns a logger attaching fields to every entry of l. Loggers
+// without structured output are returned unchanged.
+func WithFields(l Logger, fields map[string]string) Logger {
+	f, ok := l.(FieldLogger)
+	if !ok {
+		return l
+	}
+	return f.WithFields(fields)
+}
+
+// ConsoleLogger writes to stderr, for services run from a terminal.
+var ConsoleLogger Logger = consoleLogger{
+	info: log.New(os.Stderr, "I: ", log.Ltime),
+	warn: log.New(os.Stderr, "W: ", log.Ltime),
+	err:  log.New(os.Stderr, "E: ", log.Ltime),
+}
+
+type consoleLogger struct {
+	info, warn, err *log.Logger
+}
+
+func (c consoleLogger) Error(v ...interface{}) error {
+	c.err.Print(v...)
+	return nil
+}
+func (c consoleLogger) Warning(v ...interface{}) error {
+	c.warn.Print(v...)
+	return nil
+}
+func (c consoleLogger) Info(v ...interface{}) error {
+	c.info.Print(v...)
+	return nil
+}
+func (c consoleLogger) Errorf(format string, a ...interface{}) error {
+	c.err.Printf(format, a...)
+	return nil
+}
+func (c consoleLogger) Warningf(format string, a ...interface{}) error {
+	c.warn.Printf(format, a...)
+	return nil
+}
+func (c consoleLogger) Infof(format string, a ...interface{}) error {
+	c.info.Printf(format, a...)
+	return nil
+}
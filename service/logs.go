@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// Syslog priorities found in LogEntry.Priority.
+const (
+	PriorityErr     = 3
+	PriorityWarning = 4
+	PriorityInfo    = 6
+)
+
+// LogEntry is one message the service logged.
+type LogEntry struct {
+	Time     time.Time // Zero when the source has no timestamps, as plain log files.
+	Priority int       // Syslog priority, 0 (emerg) to 7 (debug).
+	Message  string
+}
+
+// LogOptions selects the entries Logs returns.
+type LogOptions struct {
+	Since  time.Time // Zero for no lower bound.
+	Until  time.Time // Zero for no upper bound.
+	Lines  int       // Only the last Lines entries, 0 for all.
+	Follow bool      // Keep waiting for new entries until the context is done.
+}
+
+// LogStream hands out log entries in order. Next returns io.EOF once all
+// entries were read; when following it blocks for new ones instead, until
+// the context passed to Logs is done.
+type LogStream interface {
+	Next() (LogEntry, error)
+	Close() error
+}
+
+// LogReader is implemented by services able to read back their own logs.
+type LogReader interface {
+	Logs(ctx context.Context, opts LogOptions) (LogStream, error)
+}
+
+// Logs streams the logs of s, or returns ErrNotSupported when the backend
+// cannot read them.
+func Logs(ctx context.Context, s Service, opts LogOptions) (LogStream, error) {
+	r, ok := s.(LogReader)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return r.Logs(ctx, opts)
+}
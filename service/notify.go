@@ -0,0 +1,84 @@
+package service
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notify sends a state string such as "READY=1" to the service manager over
+// $NOTIFY_SOCKET, see sd_notify(3). It does nothing and returns nil when the
+// process was not started with a notification socket.
+func Notify(state ...string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(strings.Join(state, "\n")))
+	return err
+}
+
+// NotifyReady tells the service manager start up is finished.
+func NotifyReady() error { return Notify("READY=1") }
+
+// NotifyStopping tells the service manager the service is shutting down.
+func NotifyStopping() error { return Notify("STOPPING=1") }
+
+// NotifyReloading tells the service manager the service is reloading its
+// configuration. Call NotifyReady once done.
+func NotifyReloading() error { return Notify("RELOADING=1") }
+
+// NotifyStatus passes a free-form status line shown by the service manager.
+func NotifyStatus(status string) error { return Notify("STATUS=" + status) }
+
+// NotifyWatchdog keeps the service manager's watchdog from firing.
+func NotifyWatchdog() error { return Notify("WATCHDOG=1") }
+
+// WatchdogInterval returns the watchdog timeout the service manager expects
+// pings within, and false when no watchdog is enabled for this process.
+func WatchdogInterval() (time.Duration, bool) {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0, false
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0, false
+	}
+	return time.Duration(usec) * time.Microsecond, true
+}
+
+// StartWatchdog sends WATCHDOG=1 at half the watchdog interval until the
+// returned function is called. Without a watchdog it does nothing.
+func StartWatchdog() (stop func()) {
+	interval, enabled := WatchdogInterval()
+	if !enabled {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				_ = NotifyWatchdog()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() { once.Do(func() { close(done) }) }
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func listenNotify(t *testing.T) *net.UnixConn {
+	addr := &net.UnixAddr{Name: filepath.Join(t.TempDir(), "notify"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", addr.Name)
+	return conn
+}
+
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	conn := listenNotify(t)
+
+	if err := NotifyReady(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != "READY=1" {
+		t.Errorf("Expected READY=1, got %q", got)
+	}
+
+	if err := Notify("STATUS=working", "STOPPING=1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readNotify(t, conn); got != "STATUS=working\nSTOPPING=1" {
+		t.Errorf("Unexpected message %q", got)
+	}
+}
+
+func TestNotifyWithoutSocket(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if err := NotifyReady(); err != nil {
+		t.Errorf("Expected no error without a socket, got %v", err)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	conn := listenNotify(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(1))
+	if _, enabled := WatchdogInterval(); enabled {
+		t.Fatalf("Expected watchdog meant for another process to be ignored")
+	}
+
+	t.Setenv("WATCHDOG_PID", "")
+	stop := StartWatchdog()
+	defer stop()
+	if got := readNotify(t, conn); got != "WATCHDOG=1" {
+		t.Errorf("Expected WATCHDOG=1, got %q", got)
+	}
+}
@@ -12,11 +12,17 @@ const (
 )
 
 const (
-	OptionKeepAliveDefault     = true
-	OptionRunAtLoadDefault     = false
-	OptionUserServiceDefault   = false
-	OptionSessionCreateDefault = false
-	OptionLogOutputDefault     = false
+	OptionKeepAliveDefault        = true
+	OptionRunAtLoadDefault        = false
+	OptionUserServiceDefault      = false
+	OptionSessionCreateDefault    = false
+	OptionLogOutputDefault        = false
+	OptionSystemdDBusDefault      = false
+	OptionNotifyDefault           = false
+	OptionTemplateDefault         = false
+	OptionUpgradeDefault          = false
+	OptionRestartOnUpgradeDefault = false
+	OptionLingerDefault           = false
 
 	OptionRunAtLoad           = "RunAtLoad"
 	OptionKeepAlive           = "KeepAlive"
@@ -39,6 +45,15 @@ const (
 	OptionOpenRCScript        = "OpenRCScript"
 	OptionLogDirectory        = "LogDirectory"
 	OptionLogDirectoryDefault = "LogDirectoryDefault"
+	OptionSystemdDBus         = "SystemdDBus"
+	OptionNotify              = "Notify"
+	OptionWatchdogSec         = "WatchdogSec"
+	OptionTemplate            = "Template"
+	OptionHardening           = "Hardening"
+	OptionUpgrade             = "Upgrade"
+	OptionRestartOnUpgrade    = "RestartOnUpgrade"
+	OptionLinger              = "Linger"
+	OptionGroup               = "Group"
 
 	OptionLimitNOFILEDefault = -1
 )
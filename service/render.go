@@ -0,0 +1,31 @@
+package service
+
+import "os"
+
+// RenderedFile is a file Install would write.
+type RenderedFile struct {
+	Path    string
+	Mode    os.FileMode
+	Content []byte
+}
+
+// Rendering is what Install would do to the system, without doing it.
+type Rendering struct {
+	Files    []RenderedFile
+	Commands [][]string // Commands run once the files are in place, in order.
+}
+
+// Renderer is implemented by services able to preview their installation.
+type Renderer interface {
+	Render() (*Rendering, error)
+}
+
+// Render previews the installation of s, or returns ErrNotSupported when
+// the backend cannot.
+func Render(s Service) (*Rendering, error) {
+	r, ok := s.(Renderer)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return r.Render()
+}
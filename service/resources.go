@@ -0,0 +1,80 @@
+package service
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RlimitNames lists the resources accepted as Resources.Limits keys.
+var RlimitNames = []string{
+	"CPU", "FSIZE", "DATA", "STACK", "CORE", "RSS", "NOFILE", "AS",
+	"NPROC", "MEMLOCK", "LOCKS", "SIGPENDING", "MSGQUEUE", "NICE", "RTPRIO", "RTTIME",
+}
+
+var (
+	sizeValue    = regexp.MustCompile(`^([0-9]+(\.[0-9]+)?[KMGT]?|[0-9]+(\.[0-9]+)?%|infinity)$`)
+	percentValue = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?%$`)
+	tasksValue   = regexp.MustCompile(`^([0-9]+%?|infinity)$`)
+	rlimitValue  = regexp.MustCompile(`^([0-9]+[A-Za-z]*|infinity)$`)
+)
+
+// Resources caps what the service may consume. Empty or zero fields leave
+// the service manager's defaults alone.
+type Resources struct {
+	MemoryMax      string            // Bytes with an optional K, M, G or T suffix, a percentage or infinity.
+	MemoryHigh     string            // Same syntax as MemoryMax.
+	CPUQuota       string            // Percentage of one CPU, e.g. "150%".
+	CPUWeight      int               // 1 to 10000.
+	TasksMax       string            // Count, percentage or infinity.
+	IOWeight       int               // 1 to 10000.
+	Nice           *int              // -20 to 19.
+	OOMScoreAdjust *int              // -1000 to 1000.
+	Limits         map[string]string // rlimits by name without the Limit prefix, e.g. "NOFILE": "65536" or "1024:4096".
+}
+
+// Validate checks the syntax of every setting.
+func (r *Resources) Validate() error {
+	for name, v := range map[string]string{"MemoryMax": r.MemoryMax, "MemoryHigh": r.MemoryHigh} {
+		if v != "" && !sizeValue.MatchString(v) {
+			return fmt.Errorf("%s: invalid size %q", name, v)
+		}
+	}
+	if r.CPUQuota != "" && !percentValue.MatchString(r.CPUQuota) {
+		return fmt.Errorf("CPUQuota: invalid percentage %q", r.CPUQuota)
+	}
+	if r.TasksMax != "" && !tasksValue.MatchString(r.TasksMax) {
+		return fmt.Errorf("TasksMax: invalid value %q", r.TasksMax)
+	}
+	for name, v := range map[string]int{"CPUWeight": r.CPUWeight, "IOWeight": r.IOWeight} {
+		if v != 0 && (v < 1 || v > 10000) {
+			return fmt.Errorf("%s: %d out of range 1-10000", name, v)
+		}
+	}
+	if r.Nice != nil && (*r.Nice < -20 || *r.Nice > 19) {
+		return fmt.Errorf("Nice: %d out of range -20-19", *r.Nice)
+	}
+	if r.OOMScoreAdjust != nil && (*r.OOMScoreAdjust < -1000 || *r.OOMScoreAdjust > 1000) {
+		return fmt.Errorf("OOMScoreAdjust: %d out of range -1000-1000", *r.OOMScoreAdjust)
+	}
+
+	for name, v := range r.Limits {
+		if !isRlimitName(name) {
+			return fmt.Errorf("unknown rlimit %q", name)
+		}
+		soft, hard, _ := strings.Cut(v, ":")
+		if !rlimitValue.MatchString(soft) || (hard != "" && !rlimitValue.MatchString(hard)) {
+			return fmt.Errorf("Limit%s: invalid value %q", name, v)
+		}
+	}
+	return nil
+}
+
+func isRlimitName(name string) bool {
+	for _, n := range RlimitNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
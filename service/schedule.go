@@ -0,0 +1,21 @@
+package service
+
+// Schedule turns the service into a batch job the service manager starts at
+// the given times instead of keeping it running. Time values use the
+// systemd.time(7) syntax.
+type Schedule struct {
+	OnCalendar         string // Calendar expression, e.g. "daily" or "Mon..Fri *-*-* 02:00".
+	OnBootSec          string // Delay after boot, e.g. "15min".
+	RandomizedDelaySec string // Random delay added to each run, spreads load across hosts.
+	Persistent         bool   // Catch up on runs missed while the machine was off.
+}
+
+// Job is implemented by controllers of scheduled services. Start must not
+// block, as for any Controller; Run waits for Done to be closed, or for the
+// service manager to stop the job early, before calling Stop. A scheduled
+// service whose controller is not a Job is done once Start returns, so
+// such a Start has to block until the work is finished.
+type Job interface {
+	Controller
+	Done() <-chan struct{}
+}
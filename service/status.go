@@ -0,0 +1,48 @@
+package service
+
+import "time"
+
+// StatusDetail is a richer view of a service's state than Status. Fields a
+// backend cannot report are left at their zero value; Properties keeps the
+// raw values the backend read them from.
+type StatusDetail struct {
+	Status               Status
+	ActiveState          string // active, reloading, inactive, failed, activating, deactivating
+	SubState             string // Backend specific, e.g. running, dead, auto-restart.
+	LoadState            string // loaded, not-found, masked, ...
+	UnitFileState        string // enabled, disabled, static, ...
+	MainPID              int
+	ExecMainStatus       int // Exit status of the last main process.
+	NRestarts            int // Automatic restarts since the service was last started by hand.
+	ActiveEnterTimestamp time.Time
+	MemoryCurrent        uint64 // Bytes, 0 when accounting is off.
+	CPUUsage             time.Duration
+	LastTrigger          time.Time  // Last run of a scheduled service.
+	NextTrigger          time.Time  // Next run of a scheduled service.
+	Resources            *Resources // Limits in effect, nil when the backend cannot tell.
+	Properties           map[string]string
+}
+
+// StatusDetailer is implemented by services able to report a StatusDetail.
+type StatusDetailer interface {
+	StatusDetail() (StatusDetail, error)
+}
+
+// Detail returns the StatusDetail of s, or ErrNotSupported when the backend
+// cannot provide one.
+func Detail(s Service) (StatusDetail, error) {
+	d, ok := s.(StatusDetailer)
+	if !ok {
+		return StatusDetail{}, ErrNotSupported
+	}
+	return d.StatusDetail()
+}
+
+// CrashLooping reports whether the service keeps dying and being restarted
+// by the service manager, as opposed to having been stopped on purpose.
+func (d StatusDetail) CrashLooping() bool {
+	if d.SubState == "auto-restart" {
+		return true
+	}
+	return d.ActiveState == "failed" && d.NRestarts > 0
+}
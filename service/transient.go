@@ -0,0 +1,35 @@
+package service
+
+// TransientOptions controls RunTransient.
+type TransientOptions struct {
+	Unit string // Unit name; derived from Config.Name, the time and a random suffix when empty.
+	Wait bool   // Block until the job exits and report how it ended.
+}
+
+// TransientResult reports a transient job. Result and ExitStatus are only
+// set when waiting.
+type TransientResult struct {
+	Unit       string
+	Result     string // success, exit-code, signal, timeout, ...
+	ExitStatus int    // Exit status of the main process, -1 when it was killed by a signal.
+}
+
+// Success reports whether the job ran to completion with exit status 0.
+func (r TransientResult) Success() bool { return r.Result == "success" }
+
+// TransientRunner is implemented by backends able to run the service as a
+// one-off job under the service manager, with its limits and logging, but
+// without installing anything.
+type TransientRunner interface {
+	RunTransient(opts TransientOptions) (TransientResult, error)
+}
+
+// RunTransient runs s as a one-off job, or returns ErrNotSupported when the
+// backend cannot.
+func RunTransient(s Service, opts TransientOptions) (TransientResult, error) {
+	r, ok := s.(TransientRunner)
+	if !ok {
+		return TransientResult{}, ErrNotSupported
+	}
+	return r.RunTransient(opts)
+}